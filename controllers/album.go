@@ -0,0 +1,459 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// decodeAlbum читает и проверяет тело запроса с альбомом. Дата релиза переводится в формат YYYY-MM-DD.
+// При ошибке ответ клиенту уже отправлен и возвращается false
+func decodeAlbum(w http.ResponseWriter, r *http.Request) (*entities.Album, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	var album entities.Album
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if album.Title == "" || entities.CleanArtistName(album.Artist) == "" {
+		logrus.WithFields(logrus.Fields{
+			"title":  album.Title,
+			"artist": album.Artist,
+		}).Warn("Invalid album data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain title and artist!", http.StatusUnprocessableEntity)
+		return nil, false
+	}
+
+	if !checkReleaseDate(w, &album.ReleaseDate) {
+		return nil, false
+	}
+	return &album, true
+}
+
+// @Summary Get albums
+// @Description Get albums ordered by release date with pagination
+// @Tags albums
+// @Produce  json
+// @Param artistId query int false "Filter by artist id"
+// @Param page query int true "Page number"
+// @Param albumsPerPage query int true "Number of albums per page"
+// @Success 200 {array} entities.Album "Successfully fetched albums"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums [get]
+func GetAlbums(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get albums request received")
+	artistIdStr := r.URL.Query().Get("artistId")
+	pageStr := r.URL.Query().Get("page")
+	albumsPerPageStr := r.URL.Query().Get("albumsPerPage")
+
+	artistId := 0
+	if artistIdStr != "" {
+		var err error
+		artistId, err = strconv.Atoi(artistIdStr)
+		if err != nil || artistId < 1 {
+			logrus.WithField("artistId", artistIdStr).Warn("Invalid artistId parameter provided")
+			http.Error(w, "Invalid artistId parameter provided!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		logrus.WithField("page", pageStr).Warn("Invalid page parameter provided")
+		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	albumsPerPage, err := strconv.Atoi(albumsPerPageStr)
+	if err != nil || albumsPerPage < 1 {
+		logrus.WithField("albumsPerPage", albumsPerPageStr).Warn("Invalid albumsPerPage parameter provided")
+		http.Error(w, "Invalid albumsPerPage parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	albums, err := models.GetAlbums(artistId, albumsPerPage, (page-1)*albumsPerPage)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"artistId":      artistId,
+			"page":          page,
+			"albumsPerPage": albumsPerPage,
+			"error":         err,
+		}).Error("Error fetching albums")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"page":   page,
+		"albums": len(albums),
+	}).Info("Fetched albums successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&albums); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get an album
+// @Description Get album by id
+// @Tags albums
+// @Produce  json
+// @Param id path int true "Album id"
+// @Success 200 {object} entities.Album "Successfully fetched album"
+// @Failure 400 {string} string "Invalid album id"
+// @Failure 404 {string} string "No album with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id} [get]
+func GetAlbum(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get album request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid album id!", http.StatusBadRequest)
+		return
+	}
+
+	album, err := models.GetAlbum(id)
+	if err != nil && errors.Is(err, models.ErrNoAlbumFound) {
+		logrus.WithField("album_id", id).Warn("No album with provided id")
+		http.Error(w, "No album with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"error":    err,
+		}).Error("Error fetching album")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("album_id", id).Info("Fetched album successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(album); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Add a new album
+// @Description Adds a new album. The artist is matched by name the same way as the group of a song and is created if missing
+// @Tags albums
+// @Accept  json
+// @Produce  json
+// @Param album body entities.Album true "Album object containing title, artist and optional releaseDate (DD.MM.YYYY) and coverLink"
+// @Success 201 {object} entities.Album "Album created successfully"
+// @Header 201 {string} Location "Path of the created album"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Failure 422 {string} string "Incorrect album data provided or has invalid format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /albums [post]
+func AddAlbum(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Add album request received")
+	album, ok := decodeAlbum(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.AddAlbum(album); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"title":  album.Title,
+			"artist": album.Artist,
+			"error":  err,
+		}).Error("Error adding album to database")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"album_id": album.Id,
+		"title":    album.Title,
+		"artist":   album.Artist,
+	}).Info("Album successfully added")
+
+	w.Header().Set("Location", fmt.Sprintf("/albums/%d", album.Id))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(album); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// @Summary Update an album
+// @Description Update album by id. The request body must contain title and artist, releaseDate (DD.MM.YYYY) and coverLink are optional
+// @Tags albums
+// @Accept json
+// @Param id path int true "Album id"
+// @Param album body entities.Album true "Album object that needs to be updated"
+// @Success 204 "Successfully updated"
+// @Failure 400 {string} string "Invalid request body or album id"
+// @Failure 404 {string} string "No album with such id"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Incorrect album data provided or has invalid format"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id} [put]
+func UpdateAlbum(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Update album request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	album, ok := decodeAlbum(w, r)
+	if !ok {
+		return
+	}
+
+	err = models.UpdateAlbum(id, album)
+	if err != nil && errors.Is(err, models.ErrNoAlbumFound) {
+		logrus.WithField("album_id", id).Warn("No album with provided id")
+		http.Error(w, "No album with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"error":    err,
+		}).Error("Error updating album")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("album_id", id).Info("Album updated successfully")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete an album
+// @Description Delete an album by id. Songs of the album stay in the library
+// @Tags albums
+// @Param id path int true "Album id"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Successfully deleted"
+// @Failure 400 {string} string "Invalid album id"
+// @Failure 404 {string} string "No album with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id} [delete]
+func DeleteAlbum(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Deleting album request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.DeleteAlbum(id, r.Header.Get("X-Actor"))
+	if err != nil && errors.Is(err, models.ErrNoAlbumFound) {
+		logrus.WithField("album_id", id).Warn("No album with provided id")
+		http.Error(w, "No album with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"error":    err,
+		}).Error("Error deleting album")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("album_id", id).Info("Album successfully deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get album songs
+// @Description Get songs of the album ordered by track number
+// @Tags albums
+// @Produce  json
+// @Param id path int true "Album id"
+// @Success 200 {array} entities.Song "Successfully fetched album songs"
+// @Failure 400 {string} string "Invalid album id"
+// @Failure 404 {string} string "No album with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id}/songs [get]
+func GetAlbumSongs(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get album songs request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid album id!", http.StatusBadRequest)
+		return
+	}
+
+	songs, err := models.GetAlbumSongs(id)
+	if err != nil && errors.Is(err, models.ErrNoAlbumFound) {
+		logrus.WithField("album_id", id).Warn("No album with provided id")
+		http.Error(w, "No album with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"error":    err,
+		}).Error("Error fetching album songs")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"album_id": id,
+		"songs":    len(songs),
+	}).Info("Fetched album songs successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&songs); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Put a song into an album
+// @Description Puts the song into the album under the given track number. A song that belongs to another album is moved
+// @Tags albums
+// @Accept json
+// @Param id path int true "Album id"
+// @Param track body entities.AlbumTrack true "Song id and track number"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Song successfully put into the album"
+// @Failure 400 {string} string "Invalid request body or album id"
+// @Failure 404 {string} string "No album or song with such id"
+// @Failure 409 {string} string "Track number is already taken"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Incorrect song id or track number"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id}/songs [post]
+func SetAlbumTrack(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Set album track request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	var track entities.AlbumTrack
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if track.SongId < 1 || track.TrackNumber < 1 {
+		logrus.WithFields(logrus.Fields{
+			"songId":      track.SongId,
+			"trackNumber": track.TrackNumber,
+		}).Warn("Invalid album track data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain positive songId and trackNumber!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = models.SetAlbumTrack(id, &track, r.Header.Get("X-Actor"))
+	if err != nil && errors.Is(err, models.ErrNoAlbumFound) {
+		logrus.WithField("album_id", id).Warn("No album with provided id")
+		http.Error(w, "No album with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", track.SongId).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrTrackNumberTaken) {
+		logrus.WithFields(logrus.Fields{
+			"album_id":     id,
+			"track_number": track.TrackNumber,
+		}).Warn("Track number is already taken")
+		http.Error(w, "Track number is already taken in the album!", http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"song_id":  track.SongId,
+			"error":    err,
+		}).Error("Error setting album track")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"album_id":     id,
+		"song_id":      track.SongId,
+		"track_number": track.TrackNumber,
+	}).Info("Song successfully put into the album")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Remove a song from an album
+// @Description Removes the song from the album. The song itself stays in the library
+// @Tags albums
+// @Param id path int true "Album id"
+// @Param songId path int true "Song id"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Song successfully removed from the album"
+// @Failure 400 {string} string "Invalid album or song id"
+// @Failure 404 {string} string "Song is not in the album"
+// @Failure 500 {string} string "Internal server error"
+// @Router /albums/{id}/songs/{songId} [delete]
+func RemoveAlbumTrack(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Remove album track request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid album id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+	songId, err := strconv.Atoi(vars["songId"])
+	if err != nil || songId < 1 {
+		logrus.WithField("songId", vars["songId"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RemoveAlbumTrack(id, songId, r.Header.Get("X-Actor"))
+	if err != nil && errors.Is(err, models.ErrNoTrackFound) {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"song_id":  songId,
+		}).Warn("Song is not in the album")
+		http.Error(w, "Song is not in the album!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"album_id": id,
+			"song_id":  songId,
+			"error":    err,
+		}).Error("Error removing album track")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"album_id": id,
+		"song_id":  songId,
+	}).Info("Song successfully removed from the album")
+	w.WriteHeader(http.StatusNoContent)
+}
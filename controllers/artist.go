@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// @Summary Get artists
+// @Description Get artists ordered by name with pagination
+// @Tags artists
+// @Produce  json
+// @Param name query string false "Filter by artist name prefix (case-insensitive)"
+// @Param page query int true "Page number"
+// @Param artistsPerPage query int true "Number of artists per page"
+// @Success 200 {array} entities.Artist "Successfully fetched artists"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /artists [get]
+func GetArtists(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get artists request received")
+	name := r.URL.Query().Get("name")
+	pageStr := r.URL.Query().Get("page")
+	artistsPerPageStr := r.URL.Query().Get("artistsPerPage")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		logrus.WithField("page", pageStr).Warn("Invalid page parameter provided")
+		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	artistsPerPage, err := strconv.Atoi(artistsPerPageStr)
+	if err != nil || artistsPerPage < 1 {
+		logrus.WithField("artistsPerPage", artistsPerPageStr).Warn("Invalid artistsPerPage parameter provided")
+		http.Error(w, "Invalid artistsPerPage parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	artists, err := models.GetArtists(name, artistsPerPage, (page-1)*artistsPerPage)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"name":           name,
+			"page":           page,
+			"artistsPerPage": artistsPerPage,
+			"error":          err,
+		}).Error("Error fetching artists")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"page":    page,
+		"artists": len(artists),
+	}).Info("Fetched artists successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&artists); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get an artist
+// @Description Get artist by id
+// @Tags artists
+// @Produce  json
+// @Param id path int true "Artist id"
+// @Success 200 {object} entities.Artist "Successfully fetched artist"
+// @Failure 400 {string} string "Invalid artist id"
+// @Failure 404 {string} string "No artist with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /artists/{id} [get]
+func GetArtist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get artist request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid artist id provided")
+		http.Error(w, "Invalid artist id!", http.StatusBadRequest)
+		return
+	}
+
+	artist, err := models.GetArtist(id)
+	if err != nil && errors.Is(err, models.ErrNoArtistFound) {
+		logrus.WithField("artist_id", id).Warn("No artist with provided id")
+		http.Error(w, "No artist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"artist_id": id,
+			"error":     err,
+		}).Error("Error fetching artist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("artist_id", id).Info("Fetched artist successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(artist); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Add a new artist
+// @Description Adds a new artist. Artist names are compared case-insensitively ignoring extra spaces
+// @Tags artists
+// @Accept  json
+// @Produce  json
+// @Param artist body entities.Artist true "Artist object containing name and optional description"
+// @Success 201 {object} entities.Artist "Artist created successfully"
+// @Header 201 {string} Location "Path of the created artist"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 409 {string} string "Artist with such name already exists"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Failure 422 {string} string "Artist name not provided"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /artists [post]
+func AddArtist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Add artist request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var artist entities.Artist
+	if err := json.NewDecoder(r.Body).Decode(&artist); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if entities.CleanArtistName(artist.Name) == "" {
+		logrus.WithField("name", artist.Name).Warn("Invalid artist data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain name!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := models.AddArtist(&artist)
+	if err != nil && errors.Is(err, models.ErrArtistExists) {
+		logrus.WithField("name", artist.Name).Warn("Artist already exists")
+		http.Error(w, "Artist with such name already exists!", http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"name":  artist.Name,
+			"error": err,
+		}).Error("Error adding artist to database")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"artist_id": artist.Id,
+		"name":      artist.Name,
+	}).Info("Artist successfully added")
+
+	w.Header().Set("Location", fmt.Sprintf("/artists/%d", artist.Id))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(&artist); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// @Summary Update an artist
+// @Description Update artist name and description by id. Group of all artist's songs is renamed too
+// @Tags artists
+// @Accept json
+// @Param id path int true "Artist id"
+// @Param artist body entities.Artist true "Artist object that needs to be updated"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Successfully updated"
+// @Failure 400 {string} string "Invalid request body or artist id"
+// @Failure 404 {string} string "No artist with such id"
+// @Failure 409 {string} string "Another artist with such name already exists"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Artist name not provided"
+// @Failure 500 {string} string "Internal server error"
+// @Router /artists/{id} [put]
+func UpdateArtist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Update artist request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid artist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	var artist entities.Artist
+	if err := json.NewDecoder(r.Body).Decode(&artist); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if entities.CleanArtistName(artist.Name) == "" {
+		logrus.WithField("name", artist.Name).Warn("Invalid artist data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain name!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = models.UpdateArtist(id, &artist, r.Header.Get("X-Actor"))
+	if err != nil && errors.Is(err, models.ErrNoArtistFound) {
+		logrus.WithField("artist_id", id).Warn("No artist with provided id")
+		http.Error(w, "No artist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrArtistExists) {
+		logrus.WithFields(logrus.Fields{
+			"artist_id": id,
+			"name":      artist.Name,
+		}).Warn("Another artist with such name exists")
+		http.Error(w, "Another artist with such name already exists!", http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"artist_id": id,
+			"error":     err,
+		}).Error("Error updating artist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("artist_id", id).Info("Artist updated successfully")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete an artist
+// @Description Delete an artist by id. Artists having songs can't be deleted
+// @Tags artists
+// @Param id path int true "Artist id"
+// @Success 204 "Successfully deleted"
+// @Failure 400 {string} string "Invalid artist id"
+// @Failure 404 {string} string "No artist with such id"
+// @Failure 409 {string} string "Artist has songs, including songs in the trash that are listed in the message, or albums"
+// @Failure 500 {string} string "Internal server error"
+// @Router /artists/{id} [delete]
+func DeleteArtist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Deleting artist request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid artist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.DeleteArtist(id)
+	var trashedErr *models.ArtistTrashedSongsError
+	if err != nil && errors.Is(err, models.ErrNoArtistFound) {
+		logrus.WithField("artist_id", id).Warn("No artist with provided id")
+		http.Error(w, "No artist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.As(err, &trashedErr) {
+		logrus.WithFields(logrus.Fields{
+			"artist_id": id,
+			"song_ids":  trashedErr.SongIds,
+		}).Warn("Can't delete artist with songs in the trash")
+		ids := make([]string, len(trashedErr.SongIds))
+		for i, songId := range trashedErr.SongIds {
+			ids[i] = strconv.Itoa(songId)
+		}
+		http.Error(w, fmt.Sprintf("Artist has songs in the trash: %s! Restore them or delete them with permanent=true first.", strings.Join(ids, ", ")), http.StatusConflict)
+		return
+	} else if err != nil && errors.Is(err, models.ErrArtistHasSongs) {
+		logrus.WithField("artist_id", id).Warn("Can't delete artist with songs")
+		http.Error(w, "Artist has songs! Delete or move them first.", http.StatusConflict)
+		return
+	} else if err != nil && errors.Is(err, models.ErrArtistHasAlbums) {
+		logrus.WithField("artist_id", id).Warn("Can't delete artist with albums")
+		http.Error(w, "Artist has albums! Delete or move them first.", http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"artist_id": id,
+			"error":     err,
+		}).Error("Error deleting artist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("artist_id", id).Info("Artist successfully deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
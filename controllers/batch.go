@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchWorkers  = 4
+	defaultBatchMaxSongs = 1000
+	defaultBatchMaxBytes = 32 << 20
+	maxNdjsonLineSize    = 1 << 20 // текст песни помещается в строку NDJSON целиком
+)
+
+const (
+	batchStatusCreated          = "created"
+	batchStatusDuplicate        = "duplicate"
+	batchStatusInvalid          = "invalid"
+	batchStatusEnrichmentFailed = "enrichment_failed"
+	batchStatusFailed           = "failed"
+)
+
+var errTooManySongs = errors.New("too many songs in batch")
+
+// batchItem - песня из запроса или ошибка разбора соответствующей строки NDJSON
+type batchItem struct {
+	song entities.Song
+	err  error
+}
+
+// @Summary Add songs in batch
+// @Description Adds many songs in one request. The body is either a JSON array of songs (Content-Type: application/json) or one song per line (Content-Type: application/x-ndjson).
+// @Description Songs are enriched by the side API concurrently by BATCH_WORKERS workers and saved one by one, so a failure of one song doesn't affect the others.
+// @Description Songs that already exist in the library or repeat earlier songs of the same batch are skipped. The response reports the status of every song.
+// @Description With ENRICHMENT_ERROR_POLICY=store songs the side API has no data for are saved with releaseDate (DD.MM.YYYY), lyrics and link from the request; after temporary failures they stay pending and are enriched by background workers
+// @Tags songs
+// @Accept  json
+// @Accept  application/x-ndjson
+// @Produce  json
+// @Param songs body []entities.Song true "Songs containing title and group"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 200 {object} entities.BatchReport "Batch processed, see status of every song"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 413 {string} string "Too many songs in one batch or the body is larger than BATCH_MAX_BYTES"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Router /songs:batch [post]
+func AddSongsBatch(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Add songs batch request received")
+	ct := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(ct)
+	if mediaType != "application/json" && mediaType != "application/x-ndjson" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	maxSongs := envInt("BATCH_MAX_SONGS", defaultBatchMaxSongs)
+	maxBytes := envInt("BATCH_MAX_BYTES", defaultBatchMaxBytes)
+	body := http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	var items []batchItem
+	var err error
+	if mediaType == "application/x-ndjson" {
+		items, err = decodeNdjsonBatch(body, maxSongs)
+	} else {
+		items, err = decodeJsonBatch(body, maxSongs)
+	}
+	var tooLarge *http.MaxBytesError
+	if err != nil && errors.Is(err, errTooManySongs) {
+		logrus.WithField("max_songs", maxSongs).Warn("Too many songs in batch")
+		http.Error(w, fmt.Sprintf("Too many songs! At most %d songs are allowed in one batch.", maxSongs), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil && errors.As(err, &tooLarge) {
+		logrus.WithField("max_bytes", maxBytes).Warn("Batch body is too large")
+		http.Error(w, fmt.Sprintf("Request body is too large! At most %d bytes are allowed in one batch.", maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		logrus.WithField("err", err).Error("Decoding batch body error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	workers := envInt("BATCH_WORKERS", defaultBatchWorkers)
+	logrus.WithFields(logrus.Fields{
+		"songs":   len(items),
+		"workers": workers,
+	}).Debug("Processing songs batch")
+
+	report := processSongsBatch(r.Context(), items, workers, r.Header.Get("X-Actor"))
+
+	logrus.WithFields(logrus.Fields{
+		"songs":   len(items),
+		"created": report.Created,
+		"skipped": report.Skipped,
+		"failed":  report.Failed,
+	}).Info("Songs batch processed")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// envInt возвращает положительное целое из переменной окружения или значение по умолчанию
+func envInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		logrus.WithField(name, value).Warn("Invalid config value, default is used")
+		return defaultValue
+	}
+	return parsed
+}
+
+// decodeJsonBatch разбирает массив песен поэлементно и прекращает чтение, как только песен становится больше maxSongs.
+// Элемент, который не является песней, не прерывает разбор, а попадает в отчет как invalid, как и строка NDJSON.
+// Синтаксически некорректный JSON разобрать дальше нельзя, поэтому он отклоняет весь пакет
+func decodeJsonBatch(body io.Reader, maxSongs int) ([]batchItem, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("batch body should be a JSON array")
+	}
+
+	items := []batchItem{}
+	for decoder.More() {
+		if len(items) == maxSongs {
+			return nil, errTooManySongs
+		}
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var item batchItem
+		if err := json.Unmarshal(raw, &item.song); err != nil {
+			item.err = fmt.Errorf("invalid song: %w", err)
+		}
+		items = append(items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// decodeNdjsonBatch разбирает песни построчно. Строка с некорректным JSON не прерывает разбор, а попадает в отчет как invalid
+func decodeNdjsonBatch(body io.Reader, maxSongs int) ([]batchItem, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNdjsonLineSize)
+
+	items := []batchItem{}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxSongs {
+			return nil, errTooManySongs
+		}
+
+		var item batchItem
+		if err := json.Unmarshal(line, &item.song); err != nil {
+			item.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// processSongsBatch проверяет песни и отбрасывает повторы внутри пакета последовательно, а обогащение и сохранение
+// выполняет пулом из workers горутин. Результаты располагаются в порядке песен в запросе
+func processSongsBatch(ctx context.Context, items []batchItem, workers int, actor string) entities.BatchReport {
+	results := make([]entities.BatchItemResult, len(items))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				addBatchSong(ctx, &items[index].song, actor, &results[index])
+			}
+		}()
+	}
+
+	seen := map[string]int{}
+	for index, item := range items {
+		result := &results[index]
+		result.Index = index
+		result.Title = item.song.Title
+		result.Group = item.song.Group
+
+		if item.err != nil {
+			result.Status = batchStatusInvalid
+			result.Error = item.err.Error()
+			continue
+		}
+		if item.song.Title == "" || entities.CleanArtistName(item.song.Group) == "" {
+			result.Status = batchStatusInvalid
+			result.Error = "song should contain title and group"
+			continue
+		}
+		releaseDate, err := models.ParseReleaseDate(item.song.ReleaseDate)
+		if err != nil {
+			result.Status = batchStatusInvalid
+			result.Error = "releaseDate should be in format DD.MM.YYYY"
+			continue
+		}
+		items[index].song.ReleaseDate = releaseDate
+		if !validLink(item.song.Link) {
+			result.Status = batchStatusInvalid
+			result.Error = fmt.Sprintf("link should be an http(s) URL of at most %d characters", maxLinkLength)
+			continue
+		}
+
+		key := entities.NormalizeSongTitle(item.song.Title) + "\x00" + entities.NormalizeArtistName(item.song.Group)
+		if first, ok := seen[key]; ok {
+			result.Status = batchStatusDuplicate
+			result.Error = fmt.Sprintf("same song as item %d of the batch", first)
+			continue
+		}
+		seen[key] = index
+
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := entities.BatchReport{Items: results}
+	for _, result := range results {
+		switch result.Status {
+		case batchStatusCreated:
+			report.Created++
+		case batchStatusDuplicate:
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+	return report
+}
+
+func addBatchSong(ctx context.Context, song *entities.Song, actor string, result *entities.BatchItemResult) {
+	id, err := models.FindSong(song.Title, song.Group)
+	if err == nil {
+		result.Status = batchStatusDuplicate
+		result.Id = id
+		result.Error = "song already exists"
+		return
+	} else if !errors.Is(err, models.ErrNoSongFound) {
+		result.Status = batchStatusFailed
+		result.Error = http.StatusText(http.StatusInternalServerError)
+		logrus.WithFields(logrus.Fields{
+			"group": song.Group,
+			"title": song.Title,
+			"error": err,
+		}).Error("Error checking song duplicate")
+		return
+	}
+
+	// политика ENRICHMENT_ERROR_POLICY действует и на пакет: песня без данных API сохраняется с полями из запроса
+	enrichErr := enrichSong(ctx, song)
+	if enrichErr != nil && (os.Getenv("ENRICHMENT_ERROR_POLICY") != enrichmentErrorStore || errors.Is(enrichErr, context.Canceled)) {
+		result.Status = batchStatusEnrichmentFailed
+		result.Error = enrichErr.Error()
+		logrus.WithFields(logrus.Fields{
+			"group": song.Group,
+			"title": song.Title,
+			"error": enrichErr,
+		}).Warn("Error enriching song from side API")
+		return
+	}
+
+	if enrichErr != nil {
+		song.Sources = models.ClientSources(song)
+		err = models.AddSongWithoutDetails(song, actor, enrichErr.Error(), enrichmentRetryable(enrichErr))
+		if err == nil && enrichmentRetryable(enrichErr) {
+			wakeEnrichmentWorkers()
+		}
+	} else {
+		err = models.AddSong(song, actor)
+	}
+	if err != nil && errors.Is(err, models.ErrSongExists) {
+		result.Status = batchStatusDuplicate
+		result.Id = song.Id
+		result.Error = "song already exists"
+		return
+	} else if err != nil {
+		result.Status = batchStatusFailed
+		result.Error = http.StatusText(http.StatusInternalServerError)
+		logrus.WithFields(logrus.Fields{
+			"group": song.Group,
+			"title": song.Title,
+			"error": err,
+		}).Error("Error adding song to database")
+		return
+	}
+
+	result.Status = batchStatusCreated
+	result.Id = song.Id
+	if enrichErr != nil {
+		result.Error = "stored without side API data: " + enrichErr.Error()
+	}
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Значения параметра onConflict при добавлении песни. Без параметра на дубликат отвечается 409
+const (
+	songConflictSkip   = "skip"
+	songConflictUpdate = "update"
+)
+
+// defaultDuplicateThreshold выше порога нечеткого поиска: дубликатом считается только очень похожее название
+const defaultDuplicateThreshold = 0.6
+
+// writeExistingSong отвечает на попытку добавить уже существующую песню: 409 со ссылкой на нее или, при onConflict=skip, саму песню
+func writeExistingSong(w http.ResponseWriter, id int, onConflict string) {
+	if onConflict != songConflictSkip {
+		logrus.WithField("song_id", id).Warn("Song already exists")
+		w.Header().Set("Location", fmt.Sprintf("/songs/%d", id))
+		http.Error(w, fmt.Sprintf("Song already exists! See /songs/%d", id), http.StatusConflict)
+		return
+	}
+
+	song, err := models.GetSong(id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching existing song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("song_id", id).Info("Song already exists, skipped")
+	writeSong(w, http.StatusOK, song)
+}
+
+// updateExistingSong дополняет существующую песню непустыми полями новой песни при onConflict=update
+func updateExistingSong(w http.ResponseWriter, id int, song *entities.Song, actor string) {
+	err := models.MergeSongDetails(id, song, actor)
+	if err == nil {
+		var updated *entities.Song
+		updated, err = models.GetSong(id)
+		if err == nil {
+			logrus.WithField("song_id", id).Info("Existing song updated")
+			writeSong(w, http.StatusOK, updated)
+			return
+		}
+	}
+
+	if errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("Existing song was deleted while updating")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"error":   err,
+	}).Error("Error updating existing song")
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// @Summary Find duplicate songs
+// @Description Get pairs of songs that are likely duplicates: songs of the same or a similar artist with the same title ignoring case, punctuation and extra spaces, or with similar titles by trigram similarity. Each song gets at most 10 candidates. Exact matches go first, then the most similar pairs
+// @Tags admin
+// @Produce  json
+// @Param page query int true "Page number"
+// @Param pairsPerPage query int true "Number of pairs per page"
+// @Param threshold query number false "Minimal title and artist similarity from 0 to 1. Defaults to 0.6"
+// @Success 200 {array} entities.DuplicatePair "Successfully fetched duplicates"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/duplicates [get]
+func GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get duplicates request received")
+	pageStr := r.URL.Query().Get("page")
+	pairsPerPageStr := r.URL.Query().Get("pairsPerPage")
+	thresholdStr := r.URL.Query().Get("threshold")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		logrus.WithField("page", pageStr).Warn("Invalid page parameter provided")
+		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	pairsPerPage, err := strconv.Atoi(pairsPerPageStr)
+	if err != nil || pairsPerPage < 1 {
+		logrus.WithField("pairsPerPage", pairsPerPageStr).Warn("Invalid pairsPerPage parameter provided")
+		http.Error(w, "Invalid pairsPerPage parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	threshold := defaultDuplicateThreshold
+	if thresholdStr != "" {
+		threshold, err = strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			logrus.WithField("threshold", thresholdStr).Warn("Invalid threshold parameter provided")
+			http.Error(w, "Invalid threshold parameter provided! Should be a number from 0 to 1.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	pairs, err := models.FindDuplicates(threshold, pairsPerPage, (page-1)*pairsPerPage)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"page":      page,
+			"threshold": threshold,
+			"error":     err,
+		}).Error("Error finding duplicates")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"page":  page,
+		"pairs": len(pairs),
+	}).Info("Fetched duplicates successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&pairs); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Merge duplicate songs
+// @Description Merges songs from mergeIds into the song keepId. The kept song gets the longest lyrics, the link and release date of merged songs if it has none, their tags, playlist entries and album track if it has no album. Merged songs are moved to the trash
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Param merge body entities.MergeSongsRequest true "Song to keep and songs to merge into it"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 200 {object} entities.Song "Merged song"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 404 {string} string "One of the songs is not found"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "No songs to merge or a song is merged into itself"
+// @Failure 500 {string} string "Internal server error"
+// @Router /admin/duplicates/merge [post]
+func MergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Merge duplicates request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var merge entities.MergeSongsRequest
+	if err := json.NewDecoder(r.Body).Decode(&merge); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if merge.KeepId < 1 || len(merge.MergeIds) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"keepId":   merge.KeepId,
+			"mergeIds": merge.MergeIds,
+		}).Warn("Invalid merge data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain keepId and non-empty mergeIds!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := models.MergeSongs(merge.KeepId, merge.MergeIds, r.Header.Get("X-Actor"))
+	if err != nil && errors.Is(err, models.ErrMergeSameSong) {
+		logrus.WithField("keepId", merge.KeepId).Warn("Song merged into itself")
+		http.Error(w, "Song can't be merged into itself!", http.StatusUnprocessableEntity)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("error", err).Warn("No song to merge")
+		http.Error(w, fmt.Sprintf("No song with such id! (%v)", err), http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"keepId":   merge.KeepId,
+			"mergeIds": merge.MergeIds,
+			"error":    err,
+		}).Error("Error merging songs")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	song, err := models.GetSong(merge.KeepId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": merge.KeepId,
+			"error":   err,
+		}).Error("Error fetching merged song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"keepId":   merge.KeepId,
+		"mergeIds": merge.MergeIds,
+	}).Info("Songs merged successfully")
+	writeSong(w, http.StatusOK, song)
+}
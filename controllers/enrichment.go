@@ -0,0 +1,436 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+	"EffectiveMobileTest/songinfo"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultEnrichmentWorkers     = 4
+	defaultEnrichmentMaxAttempts = 5
+	enrichmentPollInterval       = 5 * time.Second
+	enrichmentRetryBackoff       = 30 * time.Second // задержка перед второй попыткой, дальше она удваивается
+	enrichmentMaxRetryBackoff    = time.Hour
+)
+
+// Значения параметра onEnrichmentError: отказать в добавлении песни, если сторонний API не вернул данные, или сохранить ее без них
+const (
+	enrichmentErrorReject = "reject"
+	enrichmentErrorStore  = "store"
+)
+
+const defaultMetadataProviders = songinfo.ClientProviderName
+
+var (
+	songInfo *songinfo.Client // nil, если сторонний API не входит в METADATA_PROVIDERS
+	metadata *songinfo.Registry
+)
+
+// enrichmentWakeup будит фоновые обработчики, когда появляется новая песня, чтобы не ждать следующего опроса очереди
+var enrichmentWakeup = make(chan struct{}, 1)
+
+// InitMetadataProviders создает поставщиков метаданных по настройкам из окружения. Вызывается после загрузки .env.
+// METADATA_PROVIDERS - имена поставщиков через запятую в порядке приоритета: api - сторонний API, file - каталог из METADATA_FILE.
+// METADATA_MODE задает, берутся ли все поля у первого знающего песню поставщика (first) или каждое поле отдельно (merge)
+func InitMetadataProviders() error {
+	names := os.Getenv("METADATA_PROVIDERS")
+	if names == "" {
+		names = defaultMetadataProviders
+	}
+	mode := os.Getenv("METADATA_MODE")
+	if mode == "" {
+		mode = songinfo.ModeFirst
+	}
+
+	providers := []songinfo.Provider{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case songinfo.ClientProviderName:
+			songInfo = songinfo.NewClient(songinfo.ConfigFromEnv())
+			providers = append(providers, songInfo)
+		case songinfo.FileProviderName:
+			provider, err := songinfo.NewFileProvider(os.Getenv("METADATA_FILE"))
+			if err != nil {
+				return err
+			}
+			providers = append(providers, provider)
+		default:
+			return fmt.Errorf("unknown metadata provider %q", name)
+		}
+	}
+
+	registry, err := songinfo.NewRegistry(mode, providers...)
+	if err != nil {
+		return err
+	}
+	metadata = registry
+	logrus.WithFields(logrus.Fields{
+		"providers": registry.Providers(),
+		"mode":      mode,
+	}).Info("Metadata providers initialized")
+	return nil
+}
+
+// enrichSong дополняет песню датой выхода, текстом и ссылкой от поставщиков метаданных и заполняет song.Sources.
+// Поля, которых нет у поставщиков, остаются такими, как их передал клиент. Дата переводится в формат бд YYYY-MM-DD.
+// ctx запроса клиента прерывает обращение к поставщикам, если клиент отключился
+func enrichSong(ctx context.Context, song *entities.Song) error {
+	detail, err := metadata.Lookup(ctx, song.Group, song.Title)
+	if err != nil {
+		return err
+	}
+	if !detail.ReleaseDate.IsZero() {
+		song.ReleaseDate = detail.ReleaseDate.Format("2006-01-02")
+	}
+	if detail.Lyrics != "" {
+		song.Lyrics = detail.Lyrics
+	}
+	if detail.Link != "" {
+		song.Link = detail.Link
+	}
+
+	song.Sources = models.ClientSources(song)
+	for field, source := range detail.Sources {
+		song.Sources[field] = source
+	}
+	return nil
+}
+
+// writeEnrichmentError отвечает на ошибку поставщиков метаданных: 422, если песню не знает ни один из них,
+// 503 с Retry-After, пока автомат перед API разомкнут, 504, если API не ответил вовремя, и 502 на остальные ошибки.
+// Если клиент сам отменил запрос, отвечать уже некому
+func writeEnrichmentError(w http.ResponseWriter, song *entities.Song, err error) {
+	fields := logrus.Fields{
+		"group": song.Group,
+		"title": song.Title,
+		"error": err,
+	}
+	if errors.Is(err, context.Canceled) {
+		logrus.WithFields(fields).Warn("Request canceled by client while enriching song")
+		return
+	}
+	if errors.Is(err, songinfo.ErrNotFound) {
+		logrus.WithFields(fields).Warn("Song is unknown to metadata providers")
+		http.Error(w, "Song details not found! No metadata provider knows this song.", http.StatusUnprocessableEntity)
+		return
+	}
+	var openErr *songinfo.CircuitOpenError
+	if errors.As(err, &openErr) {
+		retryAfter := int(math.Ceil(openErr.RetryAfter.Seconds()))
+		logrus.WithFields(fields).WithField("retry_after", retryAfter).Warn("Side API circuit is open")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "Song details service is temporarily unavailable! Please retry later.", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, songinfo.ErrTimeout) {
+		logrus.WithFields(fields).Error("Side API timed out")
+		http.Error(w, "Song details service didn't respond in time!", http.StatusGatewayTimeout)
+		return
+	}
+	logrus.WithFields(fields).Error("Error enriching song from side API")
+	http.Error(w, "Song details service failed!", http.StatusBadGateway)
+}
+
+// @Summary Get side API circuit breaker status
+// @Description Get the state of the circuit breaker in front of the song details side API. While the circuit is open, songs are not enriched and POST /songs responds with 503
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} entities.CircuitStatus "Circuit breaker status"
+// @Failure 404 {string} string "Side API is not among METADATA_PROVIDERS"
+// @Router /admin/song-info [get]
+func GetSongInfoStatus(w http.ResponseWriter, r *http.Request) {
+	logrus.Debug("Get song info status request received")
+	if songInfo == nil {
+		logrus.Warn("Side API is not among metadata providers")
+		http.Error(w, "Side API is not configured!", http.StatusNotFound)
+		return
+	}
+	status := songInfo.BreakerStatus()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&status); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// enrichmentAsync определяет режим добавления песни: параметр async запроса или, если его нет, переменная ENRICHMENT_ASYNC
+func enrichmentAsync(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("async")
+	if value == "" {
+		return os.Getenv("ENRICHMENT_ASYNC") == "true", nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// enrichmentErrorPolicy возвращает параметр onEnrichmentError запроса или, если его нет, переменную ENRICHMENT_ERROR_POLICY. По умолчанию reject
+func enrichmentErrorPolicy(r *http.Request) string {
+	if policy := r.URL.Query().Get("onEnrichmentError"); policy != "" {
+		return policy
+	}
+	if policy := os.Getenv("ENRICHMENT_ERROR_POLICY"); policy == enrichmentErrorStore {
+		return policy
+	}
+	return enrichmentErrorReject
+}
+
+// addSongAsync сохраняет песню без данных стороннего API и отвечает 202, не дожидаясь обогащения.
+// existingId - уже существующая песня, 0 если ее нет. При onConflict=update она ставится в очередь на повторное обогащение
+func addSongAsync(w http.ResponseWriter, song *entities.Song, existingId int, onConflict, actor string) {
+	if existingId == 0 {
+		song.Sources = models.ClientSources(song)
+		err := models.AddPendingSong(song, actor)
+		if err == nil {
+			logrus.WithFields(logrus.Fields{
+				"song_id": song.Id,
+				"group":   song.Group,
+				"title":   song.Title,
+			}).Info("Song added, enrichment is pending")
+			wakeEnrichmentWorkers()
+			writeSong(w, http.StatusAccepted, song)
+			return
+		} else if !errors.Is(err, models.ErrSongExists) {
+			logrus.WithFields(logrus.Fields{
+				"group": song.Group,
+				"title": song.Title,
+				"error": err,
+			}).Error("Error adding song to database")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// такую же песню успели добавить параллельным запросом
+		existingId = song.Id
+		if onConflict != songConflictUpdate {
+			writeExistingSong(w, existingId, onConflict)
+			return
+		}
+	}
+	requestSongEnrichment(w, existingId)
+}
+
+// requestSongEnrichment ставит песню в очередь на обогащение и отвечает 202 с состоянием обогащения
+func requestSongEnrichment(w http.ResponseWriter, id int) {
+	err := models.RequestEnrichment(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error requesting song enrichment")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	wakeEnrichmentWorkers()
+
+	enrichment, err := models.GetSongEnrichment(id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song enrichment")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("song_id", id).Info("Song enrichment requested")
+	w.Header().Set("Location", fmt.Sprintf("/songs/%d/enrichment", id))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(enrichment); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// enrichmentRetryable сообщает, может ли повторная попытка обогащения дать данные: после таймаута, сбоя или ответа 5xx API
+// и пока автомат перед ним разомкнут. Песня, которую поставщики не знают, и ответ 4xx при повторе не изменятся
+func enrichmentRetryable(err error) bool {
+	var statusErr *songinfo.StatusError
+	switch {
+	case errors.Is(err, songinfo.ErrNotFound):
+		return false
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	default:
+		return true
+	}
+}
+
+func wakeEnrichmentWorkers() {
+	select {
+	case enrichmentWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// StartEnrichmentWorkers запускает ENRICHMENT_WORKERS фоновых обработчиков, которые обогащают песни, добавленные в асинхронном режиме.
+// Неудачная попытка повторяется с удваивающейся задержкой, после ENRICHMENT_MAX_ATTEMPTS попыток песня получает статус failed.
+// Вызывается после InitMetadataProviders
+func StartEnrichmentWorkers() {
+	workers := envInt("ENRICHMENT_WORKERS", defaultEnrichmentWorkers)
+	maxAttempts := envInt("ENRICHMENT_MAX_ATTEMPTS", defaultEnrichmentMaxAttempts)
+	jobs := make(chan models.EnrichmentJob)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				processEnrichment(job, maxAttempts)
+			}
+		}()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workers":      workers,
+		"max_attempts": maxAttempts,
+	}).Info("Enrichment workers started")
+	go func() {
+		ticker := time.NewTicker(enrichmentPollInterval)
+		defer ticker.Stop()
+		for {
+			claimed, err := models.ClaimEnrichments(workers)
+			if err != nil {
+				logrus.WithField("error", err).Error("Error claiming songs for enrichment")
+			}
+			for _, job := range claimed {
+				jobs <- job
+			}
+			// полная выборка значит, что в очереди могут остаться песни, и ждать опроса не нужно
+			if len(claimed) == workers {
+				continue
+			}
+			select {
+			case <-ticker.C:
+			case <-enrichmentWakeup:
+			}
+		}
+	}()
+}
+
+func processEnrichment(job models.EnrichmentJob, maxAttempts int) {
+	fields := logrus.Fields{
+		"song_id": job.SongId,
+		"group":   job.Group,
+		"title":   job.Title,
+		"attempt": job.Attempts + 1,
+	}
+	song := entities.Song{Title: job.Title, Group: job.Group}
+	err := enrichSong(context.Background(), &song)
+
+	var openErr *songinfo.CircuitOpenError
+	switch {
+	case err == nil:
+		err = models.CompleteEnrichment(job.SongId, &song)
+		if err != nil && errors.Is(err, models.ErrNoSongFound) {
+			logrus.WithFields(fields).Warn("Song was deleted or re-queued while enriching")
+			return
+		}
+		if err == nil {
+			logrus.WithFields(fields).Info("Song enriched")
+		}
+	case errors.As(err, &openErr):
+		logrus.WithFields(fields).WithField("retry_after", openErr.RetryAfter).Debug("Side API circuit is open, enrichment postponed")
+		err = models.RescheduleEnrichment(job.SongId, err.Error(), time.Now().Add(openErr.RetryAfter), false)
+	case !enrichmentRetryable(err), job.Attempts+1 >= maxAttempts:
+		logrus.WithFields(fields).WithField("error", err).Error("Song enrichment failed")
+		err = models.FailEnrichment(job.SongId, err.Error())
+	default:
+		delay := enrichmentRetryBackoff << job.Attempts
+		if delay <= 0 || delay > enrichmentMaxRetryBackoff {
+			delay = enrichmentMaxRetryBackoff
+		}
+		logrus.WithFields(fields).WithFields(logrus.Fields{
+			"error": err,
+			"delay": delay,
+		}).Warn("Song enrichment attempt failed, retrying later")
+		err = models.RescheduleEnrichment(job.SongId, err.Error(), time.Now().Add(delay), true)
+	}
+	if err != nil {
+		logrus.WithFields(fields).WithField("error", err).Error("Error saving song enrichment")
+	}
+}
+
+// @Summary Get song enrichment status
+// @Description Get the state of filling the song with side API data. Songs added in async mode are pending until background workers enrich them
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song id"
+// @Success 200 {object} entities.SongEnrichment "Song enrichment status"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song found with the provided id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/enrichment [get]
+func GetSongEnrichment(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get song enrichment request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	enrichment, err := models.GetSongEnrichment(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song enrichment")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"status":  enrichment.Status,
+	}).Info("Fetched song enrichment successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(enrichment); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Re-enrich a song
+// @Description Queues the song for enrichment by background workers, even if it is already enriched or enrichment failed. Fields are overwritten with non-empty side API data
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song id"
+// @Success 202 {object} entities.SongEnrichment "Song queued for enrichment"
+// @Header 202 {string} Location "Path of the song enrichment status"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song found with the provided id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/enrichment [post]
+func EnrichSong(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Re-enrich song request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	requestSongEnrichment(w, id)
+}
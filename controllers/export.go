@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// libraryExporter пишет песни в ответ в одном из форматов выгрузки. Заголовки ответа отправляются вместе с первой песней,
+// поэтому ошибка до начала выгрузки еще может быть передана клиенту обычным кодом ответа
+type libraryExporter struct {
+	w       http.ResponseWriter
+	format  string
+	started bool
+	songs   int
+	csv     *csv.Writer
+}
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+}
+
+// @Summary Export songs library
+// @Description Downloads the whole library or its part as a file. Accepts the same filters and sort as /library, but without pagination.
+// @Description Songs are streamed from the database row by row, so the export of a big library doesn't load it into memory
+// @Tags library
+// @Produce  json
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Param format query string false "File format: csv, ndjson or json (default)"
+// @Param title query string false "Filter by song title"
+// @Param group query string false "Filter by group name"
+// @Param releaseDate query string false "Filter by release date (format DD.MM.YYYY)"
+// @Param releasedFrom query string false "Filter songs released on or after the date (format DD.MM.YYYY)"
+// @Param releasedTo query string false "Filter songs released on or before the date (format DD.MM.YYYY)"
+// @Param releaseYear query int false "Filter songs released in the year"
+// @Param lyrics query string false "Filter by lyrics"
+// @Param q query string false "Full-text search in lyrics (web search syntax)"
+// @Param link query string false "Filter by link to clip"
+// @Param sort query string false "Comma-separated sort fields: id, title, group, releaseDate. Prefix a field with '-' for descending order"
+// @Param album query int false "Filter by album id"
+// @Param tags query string false "Comma-separated list of genres or tags"
+// @Param tagsMode query string false "any (default) or all"
+// @Param fuzzy query bool false "Typo-tolerant matching of title and group"
+// @Param threshold query number false "Minimal similarity from 0 to 1 in fuzzy mode"
+// @Success 200 {array} entities.Song "Library file"
+// @Header 200 {string} Content-Disposition "Attachment with the file name, e.g. library-2024-01-31.csv"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /library/export [get]
+func ExportLibrary(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Export library request received")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		logrus.WithField("format", format).Warn("Invalid format parameter provided")
+		http.Error(w, "Invalid format parameter provided! Use csv, ndjson or json.", http.StatusBadRequest)
+		return
+	}
+
+	filter, sort, ok := parseLibraryFilter(w, r)
+	if !ok {
+		return
+	}
+
+	exporter := &libraryExporter{w: w, format: format}
+	err := models.ExportLibrary(filter, sort, exporter.write)
+	if err == nil {
+		err = exporter.finish()
+	}
+	if err != nil && !exporter.started {
+		logrus.WithField("err", err).Error("Error exporting library")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		// заголовки уже отправлены, поэтому клиент получит обрезанный файл
+		logrus.WithFields(logrus.Fields{
+			"format": format,
+			"songs":  exporter.songs,
+			"err":    err,
+		}).Error("Library export interrupted")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"format": format,
+		"songs":  exporter.songs,
+	}).Info("Library exported successfully")
+}
+
+func (e *libraryExporter) start() error {
+	e.started = true
+	filename := fmt.Sprintf("library-%s.%s", time.Now().Format("2006-01-02"), e.format)
+	e.w.Header().Set("Content-Type", exportContentTypes[e.format])
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	e.w.WriteHeader(http.StatusOK)
+
+	switch e.format {
+	case "csv":
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write([]string{"id", "title", "group", "artistId", "releaseDate", "lyrics", "link", "albumId", "trackNumber"})
+	case "json":
+		_, err := io.WriteString(e.w, "[")
+		return err
+	}
+	return nil
+}
+
+func (e *libraryExporter) write(song *entities.Song) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	e.songs++
+
+	switch e.format {
+	case "csv":
+		return e.csv.Write([]string{strconv.Itoa(song.Id), song.Title, song.Group, strconv.Itoa(song.ArtistId), song.ReleaseDate,
+			song.Lyrics, song.Link, optionalInt(song.AlbumId), optionalInt(song.TrackNumber)})
+	case "json":
+		body, err := json.Marshal(song)
+		if err != nil {
+			return err
+		}
+		if e.songs > 1 {
+			body = append([]byte(","), body...)
+		}
+		_, err = e.w.Write(body)
+		return err
+	default:
+		return json.NewEncoder(e.w).Encode(song)
+	}
+}
+
+// finish дописывает окончание файла. Пустая выгрузка тоже отдается файлом с заголовком или пустым массивом
+func (e *libraryExporter) finish() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	switch e.format {
+	case "csv":
+		e.csv.Flush()
+		return e.csv.Error()
+	case "json":
+		_, err := io.WriteString(e.w, "]\n")
+		return err
+	}
+	return nil
+}
+
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
@@ -2,42 +2,68 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
-	"time"
+	"strings"
 
+	"EffectiveMobileTest/entities"
 	"EffectiveMobileTest/models"
 
 	"github.com/sirupsen/logrus"
 )
 
+// defaultFuzzyThreshold совпадает со значением pg_trgm.similarity_threshold по умолчанию
+const defaultFuzzyThreshold = 0.3
+
 // @Summary Get songs library
-// @Description Retrieve songs from the library with optional filters and pagination
+// @Description Retrieve songs from the library with optional filters and pagination.
+// @Description Two pagination modes are supported: page mode (page and songsPerPage) returns an array of songs,
+// @Description cursor mode (cursor and songsPerPage) returns entities.LibraryPage with items and nextCursor. Pass an empty cursor to get the first page in cursor mode.
+// @Description With q parameter the songs are returned as entities.LyricsSearchHit with rank and snippet fields
 // @Tags library
 // @Accept  json
 // @Produce  json
 // @Param title query string false "Filter by song title"
 // @Param group query string false "Filter by group name"
 // @Param releaseDate query string false "Filter by release date (format DD.MM.YYYY)"
+// @Param releasedFrom query string false "Filter songs released on or after the date (format DD.MM.YYYY)"
+// @Param releasedTo query string false "Filter songs released on or before the date (format DD.MM.YYYY)"
+// @Param releaseYear query int false "Filter songs released in the year"
 // @Param lyrics query string false "Filter by lyrics"
+// @Param q query string false "Full-text search in lyrics (web search syntax: quoted phrases, OR, -word). Results are ranked by relevance unless sort is provided and each song gets a highlighted snippet. Can't be used with cursor"
+// @Param lyricsQuery query string false "Alias for q"
 // @Param link query string false "Filter by link to clip"
-// @Param page query int true "Page number"
+// @Param sort query string false "Comma-separated sort fields: id, title, group, releaseDate. Prefix a field with '-' for descending order, e.g. -releaseDate,title"
+// @Param page query int false "Page number. Required unless cursor is provided"
+// @Param cursor query string false "Opaque cursor returned as nextCursor by the previous request. Alternative to page"
 // @Param songsPerPage query int true "Number of songs per page"
-// @Success 200 {array} entities.Song "Successfully fetched songs library"
+// @Param album query int false "Filter by album id"
+// @Param tags query string false "Comma-separated list of genres or tags"
+// @Param tagsMode query string false "any (default) returns songs having at least one of tags, all returns songs having every tag"
+// @Param fuzzy query bool false "Typo-tolerant matching of title and group by trigram similarity instead of prefix search. Results are ordered by similarity unless sort is provided"
+// @Param threshold query number false "Minimal similarity from 0 to 1 in fuzzy mode. Defaults to FUZZY_THRESHOLD from config or 0.3"
+// @Param envelope query bool false "Wrap the page into entities.LibraryEnvelope with total count and links to neighbouring pages. Used in page mode only"
+// @Success 200 {array} entities.Song "Successfully fetched songs library. With cursor the page is returned as entities.LibraryPage object with items and nextCursor fields. With envelope=true the page is returned as entities.LibraryEnvelope object with items, total, page, songsPerPage, totalPages, prev and next fields. With q the songs are entities.LyricsSearchHit objects with additional rank and snippet fields, both as a plain array and inside the envelope"
 // @Failure 400 {string} string "One of query parameters is invalid"
 // @Failure 500 {string} string "Internal server error"
 // @Router /library [get]
 func GetLibrary(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Get library request received")
-	title := r.URL.Query().Get("title")
-	group := r.URL.Query().Get("group")
-	releaseDateStr := r.URL.Query().Get("releaseDate")
-	lyrics := r.URL.Query().Get("lyrics")
-	link := r.URL.Query().Get("link")
 	pageStr := r.URL.Query().Get("page")
+	cursor := r.URL.Query().Get("cursor")
+	cursorMode := r.URL.Query().Has("cursor")
 	songsPerPageStr := r.URL.Query().Get("songsPerPage")
+	envelopeStr := r.URL.Query().Get("envelope")
 
-	if pageStr == "" {
+	if cursorMode && pageStr != "" {
+		logrus.Warn("Both page and cursor parameters provided")
+		http.Error(w, "page and cursor parameters can't be used together!", http.StatusBadRequest)
+		return
+	}
+	if !cursorMode && pageStr == "" {
 		logrus.Warn("Page parameter not provided")
 		http.Error(w, "page parameter not provided!", http.StatusBadRequest)
 		return
@@ -49,22 +75,33 @@ func GetLibrary(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"title":           title,
-		"group":           group,
-		"releaseDateStr":  releaseDateStr,
-		"lyrics":          lyrics,
-		"link":            link,
 		"pageStr":         pageStr,
+		"cursor":          cursor,
 		"songsPerPageStr": songsPerPageStr,
+		"envelope":        envelopeStr,
 	}).Debug("Request to fetch songs library")
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		logrus.WithField("pageStr", pageStr).Warn("Invalid page parameter provided")
-		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+	filter, sort, ok := parseLibraryFilter(w, r)
+	if !ok {
+		return
+	}
+	if cursorMode && filter.LyricsQuery != "" {
+		logrus.Warn("Full-text search requested in cursor mode")
+		http.Error(w, "q parameter can't be used together with cursor!", http.StatusBadRequest)
 		return
 	}
 
+	page := 0
+	var err error
+	if !cursorMode {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page <= 0 {
+			logrus.WithField("pageStr", pageStr).Warn("Invalid page parameter provided")
+			http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+			return
+		}
+	}
+
 	songsPerPage, err := strconv.Atoi(songsPerPageStr)
 	if err != nil || songsPerPage < 1 {
 		logrus.WithField("songsPerPageStr", songsPerPageStr).Warn("Invalid songsPerPage parameter provided")
@@ -72,24 +109,24 @@ func GetLibrary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	envelope := false
+	if envelopeStr != "" {
+		envelope, err = strconv.ParseBool(envelopeStr)
+		if err != nil {
+			logrus.WithField("envelope", envelopeStr).Warn("Invalid envelope parameter provided")
+			http.Error(w, "Invalid envelope parameter provided!", http.StatusBadRequest)
+			return
+		}
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"page":         page,
 		"songsPerPage": songsPerPage,
 	}).Debug("Parsed page and songsPerPage")
 
-	var releaseDate time.Time
-	releaseDateFormatted := ""
-	if releaseDateStr != "" {
-		releaseDate, err = time.Parse("02.01.2006", releaseDateStr)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"releaseDateStr": releaseDateStr,
-				"err":            err,
-			}).Warn("Ivalid releaseDate format")
-			http.Error(w, "Invalid releaseDate format! Please use DD.MM.YYYY.", http.StatusBadRequest)
-			return
-		}
-		releaseDateFormatted = releaseDate.Format("2006-01-02")
+	if cursorMode {
+		getLibraryByCursor(w, filter, sort, cursor, songsPerPage)
+		return
 	}
 
 	limit := songsPerPage
@@ -100,14 +137,20 @@ func GetLibrary(w http.ResponseWriter, r *http.Request) {
 		"offset": offset,
 	}).Debug("Calculated limit and offset")
 
-	library, err := models.GetLibrary(title, group, releaseDateFormatted, lyrics, link, limit, offset)
+	var library interface{}
+	if filter.LyricsQuery != "" {
+		library, err = models.SearchLibrary(filter, sort, limit, offset)
+	} else {
+		library, err = models.GetLibrary(filter, sort, limit, offset)
+	}
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
-			"title":        title,
-			"group":        group,
-			"releaseDate":  releaseDate,
-			"lyrics":       lyrics,
-			"link":         link,
+			"title":        filter.Title,
+			"group":        filter.Group,
+			"releaseDate":  filter.ReleaseDate,
+			"lyrics":       filter.Lyrics,
+			"link":         filter.Link,
+			"sort":         sort,
 			"page":         page,
 			"songsPerPage": songsPerPage,
 			"err":          err,
@@ -117,28 +160,275 @@ func GetLibrary(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"title":        title,
-		"group":        group,
-		"releaseDate":  releaseDate,
-		"lyrics":       lyrics,
-		"link":         link,
+		"title":        filter.Title,
+		"group":        filter.Group,
+		"releaseDate":  filter.ReleaseDate,
+		"lyrics":       filter.Lyrics,
+		"link":         filter.Link,
 		"page":         page,
 		"songsPerPage": songsPerPage,
 	}).Info("Successfully fetched library data")
 
+	response := library
+	if envelope {
+		total, err := models.CountLibrary(filter)
+		if err != nil {
+			logrus.WithField("err", err).Error("Error counting library songs")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		response = newLibraryEnvelope(r, library, total, page, songsPerPage)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(&library); err != nil {
+	if err = json.NewEncoder(w).Encode(response); err != nil {
 		logrus.WithField("error", err).Error("Error encoding response")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"title":        title,
-		"group":        group,
-		"releaseDate":  releaseDate,
-		"lyrics":       lyrics,
-		"link":         link,
+		"title":        filter.Title,
+		"group":        filter.Group,
+		"releaseDate":  filter.ReleaseDate,
+		"lyrics":       filter.Lyrics,
+		"link":         filter.Link,
 		"page":         page,
 		"songsPerPage": songsPerPage,
 	}).Info("Response successfully returned")
 }
+
+// parseLibraryFilter разбирает фильтры и сортировку библиотеки из параметров запроса. Используется всеми выдачами библиотеки,
+// чтобы они фильтровали одинаково. При ошибке ответ уже отправлен и возвращается false
+func parseLibraryFilter(w http.ResponseWriter, r *http.Request) (models.LibraryFilter, []models.SortField, bool) {
+	title := r.URL.Query().Get("title")
+	group := r.URL.Query().Get("group")
+	releaseDateStr := r.URL.Query().Get("releaseDate")
+	releasedFromStr := r.URL.Query().Get("releasedFrom")
+	releasedToStr := r.URL.Query().Get("releasedTo")
+	releaseYearStr := r.URL.Query().Get("releaseYear")
+	lyrics := r.URL.Query().Get("lyrics")
+	lyricsQuery := r.URL.Query().Get("q")
+	if lyricsQuery == "" {
+		lyricsQuery = r.URL.Query().Get("lyricsQuery")
+	}
+	link := r.URL.Query().Get("link")
+	sortStr := r.URL.Query().Get("sort")
+	albumStr := r.URL.Query().Get("album")
+	tagsStr := r.URL.Query().Get("tags")
+	tagsMode := r.URL.Query().Get("tagsMode")
+	fuzzyStr := r.URL.Query().Get("fuzzy")
+	thresholdStr := r.URL.Query().Get("threshold")
+
+	logrus.WithFields(logrus.Fields{
+		"title":          title,
+		"group":          group,
+		"releaseDateStr": releaseDateStr,
+		"releasedFrom":   releasedFromStr,
+		"releasedTo":     releasedToStr,
+		"releaseYear":    releaseYearStr,
+		"lyrics":         lyrics,
+		"lyricsQuery":    lyricsQuery,
+		"link":           link,
+		"sort":           sortStr,
+		"album":          albumStr,
+		"tags":           tagsStr,
+		"tagsMode":       tagsMode,
+		"fuzzy":          fuzzyStr,
+		"threshold":      thresholdStr,
+	}).Debug("Parsing library filter")
+
+	var err error
+	album := 0
+	if albumStr != "" {
+		album, err = strconv.Atoi(albumStr)
+		if err != nil || album < 1 {
+			logrus.WithField("album", albumStr).Warn("Invalid album parameter provided")
+			http.Error(w, "Invalid album parameter provided!", http.StatusBadRequest)
+			return models.LibraryFilter{}, nil, false
+		}
+	}
+
+	tags := []string{}
+	if tagsStr != "" {
+		for _, tag := range strings.Split(tagsStr, ",") {
+			if models.NormalizeTagName(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			logrus.WithField("tags", tagsStr).Warn("Invalid tags parameter provided")
+			http.Error(w, "Invalid tags parameter provided! Use a comma-separated list of tag names.", http.StatusBadRequest)
+			return models.LibraryFilter{}, nil, false
+		}
+	}
+	if tagsMode != "" && tagsMode != "any" && tagsMode != "all" {
+		logrus.WithField("tagsMode", tagsMode).Warn("Invalid tagsMode parameter provided")
+		http.Error(w, "Invalid tagsMode parameter provided! Use any or all.", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	fuzzy := false
+	if fuzzyStr != "" {
+		fuzzy, err = strconv.ParseBool(fuzzyStr)
+		if err != nil {
+			logrus.WithField("fuzzy", fuzzyStr).Warn("Invalid fuzzy parameter provided")
+			http.Error(w, "Invalid fuzzy parameter provided!", http.StatusBadRequest)
+			return models.LibraryFilter{}, nil, false
+		}
+	}
+
+	threshold := defaultFuzzyThreshold
+	if envThreshold := os.Getenv("FUZZY_THRESHOLD"); envThreshold != "" {
+		threshold, err = strconv.ParseFloat(envThreshold, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			logrus.WithField("FUZZY_THRESHOLD", envThreshold).Warn("Invalid FUZZY_THRESHOLD in config. Using default")
+			threshold = defaultFuzzyThreshold
+		}
+	}
+	if thresholdStr != "" {
+		threshold, err = strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			logrus.WithField("threshold", thresholdStr).Warn("Invalid threshold parameter provided")
+			http.Error(w, "Invalid threshold parameter provided! Should be a number from 0 to 1.", http.StatusBadRequest)
+			return models.LibraryFilter{}, nil, false
+		}
+	}
+
+	releaseDate, err := models.ParseReleaseDate(releaseDateStr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"releaseDateStr": releaseDateStr,
+			"err":            err,
+		}).Warn("Ivalid releaseDate format")
+		http.Error(w, "Invalid releaseDate format! Please use DD.MM.YYYY.", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	releasedFrom, err := models.ParseReleaseDate(releasedFromStr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"releasedFrom": releasedFromStr,
+			"err":          err,
+		}).Warn("Invalid releasedFrom format")
+		http.Error(w, "Invalid releasedFrom format! Please use DD.MM.YYYY.", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	releasedTo, err := models.ParseReleaseDate(releasedToStr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"releasedTo": releasedToStr,
+			"err":        err,
+		}).Warn("Invalid releasedTo format")
+		http.Error(w, "Invalid releasedTo format! Please use DD.MM.YYYY.", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	if releasedFrom != "" && releasedTo != "" && releasedFrom > releasedTo {
+		logrus.WithFields(logrus.Fields{
+			"releasedFrom": releasedFromStr,
+			"releasedTo":   releasedToStr,
+		}).Warn("releasedFrom is after releasedTo")
+		http.Error(w, "releasedFrom can't be after releasedTo!", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	releaseYear := 0
+	if releaseYearStr != "" {
+		releaseYear, err = strconv.Atoi(releaseYearStr)
+		if err != nil || releaseYear < 1 || releaseYear > 9998 {
+			logrus.WithField("releaseYear", releaseYearStr).Warn("Invalid releaseYear parameter provided")
+			http.Error(w, "Invalid releaseYear parameter provided!", http.StatusBadRequest)
+			return models.LibraryFilter{}, nil, false
+		}
+	}
+
+	sort, err := models.ParseLibrarySort(sortStr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"sort": sortStr,
+			"err":  err,
+		}).Warn("Invalid sort parameter provided")
+		http.Error(w, "Invalid sort parameter! Allowed fields: id, title, group, releaseDate.", http.StatusBadRequest)
+		return models.LibraryFilter{}, nil, false
+	}
+
+	filter := models.LibraryFilter{
+		Title:        title,
+		Group:        group,
+		ReleaseDate:  releaseDate,
+		ReleasedFrom: releasedFrom,
+		ReleasedTo:   releasedTo,
+		ReleaseYear:  releaseYear,
+		Lyrics:       lyrics,
+		LyricsQuery:  lyricsQuery,
+		Link:         link,
+		AlbumId:      album,
+		Tags:         tags,
+		AllTags:      tagsMode == "all",
+		Fuzzy:        fuzzy,
+		Threshold:    threshold,
+	}
+	return filter, sort, true
+}
+
+// newLibraryEnvelope оборачивает страницу библиотеки в объект с метаданными пагинации.
+// Ссылки на соседние страницы строятся из текущего запроса с сохранением всех фильтров
+func newLibraryEnvelope(r *http.Request, library interface{}, total, page, songsPerPage int) *entities.LibraryEnvelope {
+	totalPages := (total + songsPerPage - 1) / songsPerPage
+	envelope := entities.LibraryEnvelope{
+		Items:        library,
+		Total:        total,
+		Page:         page,
+		SongsPerPage: songsPerPage,
+		TotalPages:   totalPages,
+	}
+
+	pageLink := func(page int) *string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		link := (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+		return &link
+	}
+	if page > 1 && totalPages > 0 {
+		envelope.Prev = pageLink(min(page-1, totalPages))
+	}
+	if page < totalPages {
+		envelope.Next = pageLink(page + 1)
+	}
+	return &envelope
+}
+
+func getLibraryByCursor(w http.ResponseWriter, filter models.LibraryFilter, sort []models.SortField, cursor string, songsPerPage int) {
+	var libraryPage entities.LibraryPage
+	var err error
+	libraryPage.Items, libraryPage.NextCursor, err = models.GetLibraryByCursor(filter, sort, cursor, songsPerPage)
+	if err != nil && errors.Is(err, models.ErrInvalidCursor) {
+		logrus.WithFields(logrus.Fields{
+			"cursor": cursor,
+			"err":    err,
+		}).Warn("Invalid cursor parameter provided")
+		http.Error(w, "Invalid cursor parameter provided!", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"cursor":       cursor,
+			"songsPerPage": songsPerPage,
+			"err":          err,
+		}).Error("Error fetching library data")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"cursor":       cursor,
+		"songsPerPage": songsPerPage,
+		"songs":        len(libraryPage.Items),
+	}).Info("Successfully fetched library data by cursor")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(&libraryPage); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// patchSongDocument применяет к песне JSON Merge Patch или JSON Patch. Патч применяется к документу текущей версии песни,
+// а сохраняется только если песня не изменилась с момента чтения, поэтому параллельные изменения не теряются
+func patchSongDocument(w http.ResponseWriter, r *http.Request, id, version int, mediaType string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	song, err := models.GetSong(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if version != models.AnyVersion && version != song.Version {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"if_match": version,
+		}).Warn("Song version mismatch")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return
+	}
+
+	original := models.NewSongDocument(song)
+	doc := models.NewSongDocument(song)
+	if mediaType == mergePatchMediaType {
+		err = models.ApplyMergePatch(doc, body)
+	} else {
+		err = models.ApplyJSONPatch(doc, body)
+	}
+	if err != nil && errors.Is(err, models.ErrPatchTestFailed) {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Warn("JSON Patch test failed")
+		http.Error(w, "Patch test failed! "+err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Warn("Invalid patch document")
+		http.Error(w, "Incorrect patch provided! "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// патч, который ничего не меняет, не сохраняется: иначе сменилась бы версия и ETag у всех клиентов, а в истории появилась бы пустая ревизия
+	if doc.Equal(original) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":    id,
+			"media_type": mediaType,
+		}).Info("Patch doesn't change the song, nothing to save")
+		w.Header().Set("ETag", songETag(song.Version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	patched := doc.Song()
+	if patched.Title == "" || entities.CleanArtistName(patched.Group) == "" {
+		logrus.WithFields(logrus.Fields{
+			"title": patched.Title,
+			"group": patched.Group,
+		}).Warn("Invalid song data after patch")
+		http.Error(w, "Incorrect data provided!\ntitle and group can't be removed or empty!", http.StatusUnprocessableEntity)
+		return
+	}
+	if !checkReleaseDate(w, &patched.ReleaseDate) {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":     id,
+		"media_type":  mediaType,
+		"title":       patched.Title,
+		"group":       patched.Group,
+		"releaseDate": patched.ReleaseDate,
+		"link":        patched.Link,
+	}).Debug("Trying saving patched song")
+
+	err = models.SavePatchedSong(id, &patched, r.Header.Get("X-Actor"), song.Version)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrVersionMismatch) {
+		logrus.WithField("song_id", id).Warn("Song modified while patching")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error patching song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":    id,
+		"media_type": mediaType,
+	}).Info("Song successfully patched")
+	w.Header().Set("ETag", songETag(patched.Version))
+	w.WriteHeader(http.StatusNoContent)
+}
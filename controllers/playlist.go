@@ -0,0 +1,481 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// @Summary Get playlists
+// @Description Get playlists with number of songs in each with pagination
+// @Tags playlists
+// @Produce  json
+// @Param page query int true "Page number"
+// @Param playlistsPerPage query int true "Number of playlists per page"
+// @Success 200 {array} entities.Playlist "Successfully fetched playlists"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists [get]
+func GetPlaylists(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get playlists request received")
+	pageStr := r.URL.Query().Get("page")
+	playlistsPerPageStr := r.URL.Query().Get("playlistsPerPage")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		logrus.WithField("page", pageStr).Warn("Invalid page parameter provided")
+		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	playlistsPerPage, err := strconv.Atoi(playlistsPerPageStr)
+	if err != nil || playlistsPerPage < 1 {
+		logrus.WithField("playlistsPerPage", playlistsPerPageStr).Warn("Invalid playlistsPerPage parameter provided")
+		http.Error(w, "Invalid playlistsPerPage parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	playlists, err := models.GetPlaylists(playlistsPerPage, (page-1)*playlistsPerPage)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"page":             page,
+			"playlistsPerPage": playlistsPerPage,
+			"error":            err,
+		}).Error("Error fetching playlists")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"page":      page,
+		"playlists": len(playlists),
+	}).Info("Fetched playlists successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&playlists); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get a playlist
+// @Description Get playlist by id with its entries in order
+// @Tags playlists
+// @Produce  json
+// @Param id path int true "Playlist id"
+// @Success 200 {object} entities.Playlist "Successfully fetched playlist"
+// @Failure 400 {string} string "Invalid playlist id"
+// @Failure 404 {string} string "No playlist with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id} [get]
+func GetPlaylist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get playlist request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid playlist id!", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := models.GetPlaylist(id)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"error":       err,
+		}).Error("Error fetching playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"playlist_id": id,
+		"entries":     len(playlist.Entries),
+	}).Info("Fetched playlist successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(playlist); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Create a playlist
+// @Description Creates an empty playlist
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param playlist body entities.Playlist true "Playlist object containing name"
+// @Success 201 {object} entities.Playlist "Playlist created successfully"
+// @Header 201 {string} Location "Path of the created playlist"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 415 {string} string "Unsupported Media Type"
+// @Failure 422 {string} string "Playlist name not provided"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /playlists [post]
+func AddPlaylist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Add playlist request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var playlist entities.Playlist
+	if err := json.NewDecoder(r.Body).Decode(&playlist); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	playlist.Name = strings.TrimSpace(playlist.Name)
+	if playlist.Name == "" {
+		logrus.Warn("Playlist name not provided")
+		http.Error(w, "Incorrect data provided!\nJSON should contain name!", http.StatusUnprocessableEntity)
+		return
+	}
+	playlist.Entries = nil
+
+	if err := models.AddPlaylist(&playlist); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"name":  playlist.Name,
+			"error": err,
+		}).Error("Error adding playlist to database")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"playlist_id": playlist.Id,
+		"name":        playlist.Name,
+	}).Info("Playlist successfully added")
+
+	w.Header().Set("Location", fmt.Sprintf("/playlists/%d", playlist.Id))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(&playlist); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// @Summary Rename a playlist
+// @Description Rename a playlist by id. Entries of the playlist are not changed
+// @Tags playlists
+// @Accept json
+// @Param id path int true "Playlist id"
+// @Param playlist body entities.Playlist true "Playlist object containing new name"
+// @Success 204 "Successfully renamed"
+// @Failure 400 {string} string "Invalid request body or playlist id"
+// @Failure 404 {string} string "No playlist with such id"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Playlist name not provided"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id} [put]
+func RenamePlaylist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Rename playlist request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	var playlist entities.Playlist
+	if err := json.NewDecoder(r.Body).Decode(&playlist); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	playlist.Name = strings.TrimSpace(playlist.Name)
+	if playlist.Name == "" {
+		logrus.Warn("Playlist name not provided")
+		http.Error(w, "Incorrect data provided!\nJSON should contain name!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = models.RenamePlaylist(id, playlist.Name)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"error":       err,
+		}).Error("Error renaming playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("playlist_id", id).Info("Playlist renamed successfully")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Delete a playlist
+// @Description Delete a playlist by id. Songs stay in the library
+// @Tags playlists
+// @Param id path int true "Playlist id"
+// @Success 204 "Successfully deleted"
+// @Failure 400 {string} string "Invalid playlist id"
+// @Failure 404 {string} string "No playlist with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id} [delete]
+func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Deleting playlist request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.DeletePlaylist(id)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"error":       err,
+		}).Error("Error deleting playlist")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("playlist_id", id).Info("Playlist successfully deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Add a song to a playlist
+// @Description Inserts a song into the playlist at the given position shifting the following entries. Without position the song is appended to the end
+// @Tags playlists
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Playlist id"
+// @Param entry body entities.PlaylistEntry true "Entry containing songId and optional position"
+// @Success 201 {object} entities.PlaylistEntry "Song successfully added"
+// @Failure 400 {string} string "Invalid request body or playlist id"
+// @Failure 404 {string} string "No playlist or song with such id"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Incorrect song id or position"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id}/entries [post]
+func AddPlaylistEntry(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Add playlist entry request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	var entry entities.PlaylistEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if entry.SongId < 1 || entry.Position < 0 {
+		logrus.WithFields(logrus.Fields{
+			"songId":   entry.SongId,
+			"position": entry.Position,
+		}).Warn("Invalid playlist entry data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain positive songId and optional positive position!", http.StatusUnprocessableEntity)
+		return
+	}
+	entry.Song = nil
+
+	err = models.AddPlaylistEntry(id, &entry)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", entry.SongId).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"song_id":     entry.SongId,
+			"error":       err,
+		}).Error("Error adding playlist entry")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"playlist_id": id,
+		"entry_id":    entry.Id,
+		"position":    entry.Position,
+	}).Info("Song successfully added to playlist")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(&entry); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
+}
+
+// @Summary Move a playlist entry
+// @Description Moves the entry to a new position shifting entries in between. Position beyond the end moves the entry to the end
+// @Tags playlists
+// @Accept json
+// @Param id path int true "Playlist id"
+// @Param entryId path int true "Entry id"
+// @Param entry body entities.PlaylistEntry true "Entry containing new position"
+// @Success 204 "Entry successfully moved"
+// @Failure 400 {string} string "Invalid request body or id"
+// @Failure 404 {string} string "No playlist or entry with such id"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Incorrect position"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id}/entries/{entryId} [put]
+func MovePlaylistEntry(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Move playlist entry request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+	entryId, err := strconv.Atoi(vars["entryId"])
+	if err != nil || entryId < 1 {
+		logrus.WithField("entryId", vars["entryId"]).Warn("Invalid entry id provided")
+		http.Error(w, "Invalid entry id!", http.StatusBadRequest)
+		return
+	}
+
+	var entry entities.PlaylistEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if entry.Position < 1 {
+		logrus.WithField("position", entry.Position).Warn("Invalid position provided")
+		http.Error(w, "Incorrect data provided!\nJSON should contain positive position!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = models.MovePlaylistEntry(id, entryId, entry.Position)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoPlaylistEntryFound) {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"entry_id":    entryId,
+		}).Warn("No entry with provided id in the playlist")
+		http.Error(w, "No entry with such id in the playlist!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"entry_id":    entryId,
+			"error":       err,
+		}).Error("Error moving playlist entry")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"playlist_id": id,
+		"entry_id":    entryId,
+		"position":    entry.Position,
+	}).Info("Playlist entry successfully moved")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Remove a song from a playlist
+// @Description Removes the entry from the playlist shifting the following entries
+// @Tags playlists
+// @Param id path int true "Playlist id"
+// @Param entryId path int true "Entry id"
+// @Success 204 "Entry successfully removed"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 404 {string} string "No playlist or entry with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /playlists/{id}/entries/{entryId} [delete]
+func RemovePlaylistEntry(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Remove playlist entry request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid playlist id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+	entryId, err := strconv.Atoi(vars["entryId"])
+	if err != nil || entryId < 1 {
+		logrus.WithField("entryId", vars["entryId"]).Warn("Invalid entry id provided")
+		http.Error(w, "Invalid entry id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RemovePlaylistEntry(id, entryId)
+	if err != nil && errors.Is(err, models.ErrNoPlaylistFound) {
+		logrus.WithField("playlist_id", id).Warn("No playlist with provided id")
+		http.Error(w, "No playlist with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoPlaylistEntryFound) {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"entry_id":    entryId,
+		}).Warn("No entry with provided id in the playlist")
+		http.Error(w, "No entry with such id in the playlist!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"playlist_id": id,
+			"entry_id":    entryId,
+			"error":       err,
+		}).Error("Error removing playlist entry")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"playlist_id": id,
+		"entry_id":    entryId,
+	}).Info("Playlist entry successfully removed")
+	w.WriteHeader(http.StatusNoContent)
+}
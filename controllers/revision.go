@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// @Summary Get song revisions
+// @Description Get the history of song changes, newest first. Every revision contains a full snapshot of the song. History of a deleted song is available too
+// @Tags revisions
+// @Produce  json
+// @Param id path int true "Song id"
+// @Success 200 {array} entities.SongRevision "Successfully fetched song revisions"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/revisions [get]
+func GetSongRevisions(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get song revisions request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := models.GetSongRevisions(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song revisions")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":   id,
+		"revisions": len(revisions),
+	}).Info("Fetched song revisions successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&revisions); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Get a song revision
+// @Description Get a single revision of the song with full snapshot
+// @Tags revisions
+// @Produce  json
+// @Param id path int true "Song id"
+// @Param rev path int true "Revision number"
+// @Success 200 {object} entities.SongRevision "Successfully fetched song revision"
+// @Failure 400 {string} string "Invalid song id or revision number"
+// @Failure 404 {string} string "No such revision of the song"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/revisions/{rev} [get]
+func GetSongRevision(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get song revision request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil || rev < 1 {
+		logrus.WithField("rev", vars["rev"]).Warn("Invalid revision number provided")
+		http.Error(w, "Invalid revision number!", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := models.GetSongRevision(id, rev)
+	if err != nil && errors.Is(err, models.ErrNoRevisionFound) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"revision": rev,
+		}).Warn("No such song revision")
+		http.Error(w, "No such revision of the song!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"revision": rev,
+			"error":    err,
+		}).Error("Error fetching song revision")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":  id,
+		"revision": rev,
+	}).Info("Fetched song revision successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revision); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Restore a song revision
+// @Description Returns the song to the state of the given revision. The restore itself is saved as a new revision
+// @Tags revisions
+// @Param id path int true "Song id"
+// @Param rev path int true "Revision number"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Successfully restored"
+// @Failure 400 {string} string "Invalid song id or revision number"
+// @Failure 404 {string} string "No such song or revision"
+// @Failure 409 {string} string "Another song of the artist already has the title of the revision, Location header points at it"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/revisions/{rev}/restore [post]
+func RestoreSongRevision(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Restore song revision request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil || rev < 1 {
+		logrus.WithField("rev", vars["rev"]).Warn("Invalid revision number provided")
+		http.Error(w, "Invalid revision number!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RestoreSongRevision(id, rev, r.Header.Get("X-Actor"))
+	var existsErr *models.SongExistsError
+	if err != nil && errors.Is(err, models.ErrNoRevisionFound) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"revision": rev,
+		}).Warn("No such song revision")
+		http.Error(w, "No such revision of the song!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.As(err, &existsErr) {
+		writeExistingSong(w, existsErr.SongId, "")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"revision": rev,
+			"error":    err,
+		}).Error("Error restoring song revision")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":  id,
+		"revision": rev,
+	}).Info("Song revision restored successfully")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Diff song lyrics
+// @Description Line-level diff of song lyrics between two revisions or between a revision and the current text. Lines are split into verses the same way as in /songs/{id}/lyrics, every hunk is annotated with the verse where changes start. Responds with JSON hunks by default or with a unified diff in text/plain when format=text or Accept: text/plain is requested
+// @Tags revisions
+// @Produce  json
+// @Produce  plain
+// @Param id path int true "Song id"
+// @Param from query string true "Revision number or current"
+// @Param to query string false "Revision number or current, current by default"
+// @Param format query string false "Response format: json (default) or text"
+// @Success 200 {object} entities.LyricsDiff "Successfully built lyrics diff"
+// @Failure 400 {string} string "Invalid song id, revision or format"
+// @Failure 404 {string} string "No such song or revision"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/lyrics/diff [get]
+func DiffSongLyrics(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Diff song lyrics request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	fromStr := r.URL.Query().Get("from")
+	if fromStr == "" {
+		logrus.Warn("from parameter not provided")
+		http.Error(w, "from parameter not provided!", http.StatusBadRequest)
+		return
+	}
+	from, err := parseLyricsRevision(fromStr)
+	if err != nil {
+		logrus.WithField("from", fromStr).Warn("Invalid from parameter provided")
+		http.Error(w, "Incorrect from revision!", http.StatusBadRequest)
+		return
+	}
+	toStr := r.URL.Query().Get("to")
+	to := models.CurrentLyrics
+	if toStr != "" {
+		to, err = parseLyricsRevision(toStr)
+		if err != nil {
+			logrus.WithField("to", toStr).Warn("Invalid to parameter provided")
+			http.Error(w, "Incorrect to revision!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		format = "text"
+	}
+	if format != "" && format != "json" && format != "text" {
+		logrus.WithField("format", format).Warn("Invalid format parameter provided")
+		http.Error(w, "Incorrect format, expected json or text!", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := models.DiffSongLyrics(id, from, to)
+	if err != nil && errors.Is(err, models.ErrNoRevisionFound) {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"from":    fromStr,
+			"to":      toStr,
+		}).Warn("No such song revision")
+		http.Error(w, "No such revision of the song!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error building lyrics diff")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"from":    diff.From,
+		"to":      diff.To,
+		"hunks":   len(diff.Hunks),
+	}).Info("Built lyrics diff successfully")
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := io.WriteString(w, models.FormatUnifiedLyricsDiff(diff)); err != nil {
+			logrus.WithField("error", err).Error("Error writing response")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// parseLyricsRevision разбирает номер ревизии из параметра запроса. Значение current означает текущий текст песни
+func parseLyricsRevision(value string) (int, error) {
+	if value == "current" {
+		return models.CurrentLyrics, nil
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if revision < 1 {
+		return 0, errors.New("revision number must be positive")
+	}
+	return revision, nil
+}
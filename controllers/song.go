@@ -1,14 +1,18 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"EffectiveMobileTest/entities"
 	"EffectiveMobileTest/models"
@@ -17,6 +21,127 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// maxLinkLength - размер столбца songs.link
+const maxLinkLength = 255
+
+// @Summary Get a song
+// @Description Get full song data by id. Response contains ETag and Last-Modified headers, so conditional requests with If-None-Match or If-Modified-Since are supported.
+// @Description ETag is the version of the song, pass it in If-Match to PUT, PATCH or DELETE to make sure nobody changed the song in between
+// @Tags songs
+// @Produce  json
+// @Param id path int true "Song id"
+// @Success 200 {object} entities.Song "Successfully fetched song"
+// @Success 304 "Song not modified"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id} [get]
+func GetSong(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get song request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	logrus.WithField("song_id", id).Debug("Fetching song")
+
+	song, err := models.GetSong(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(song)
+	if err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	etag := songETag(song.Version)
+	lastModified := song.UpdatedAt.UTC().Truncate(time.Second)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if isNotModified(r, etag, lastModified) {
+		logrus.WithField("song_id", id).Info("Song not modified")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		logrus.WithField("error", err).Error("Error writing response")
+		return
+	}
+	logrus.WithField("song_id", id).Info("Response successfully sent")
+}
+
+func songETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion возвращает версию песни из заголовка If-Match. Без заголовка или со значением * версия не проверяется,
+// если только REQUIRE_IF_MATCH=true не требует заголовок явно. При ошибке ответ уже отправлен и возвращается false
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" && os.Getenv("REQUIRE_IF_MATCH") == "true" {
+		logrus.Warn("If-Match header not provided")
+		http.Error(w, "If-Match header is required! Get the song first and pass its ETag.", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	if ifMatch == "" || ifMatch == "*" {
+		return models.AnyVersion, true
+	}
+	if strings.Contains(ifMatch, ",") {
+		logrus.WithField("If-Match", ifMatch).Warn("Several ETags provided in If-Match")
+		http.Error(w, "Only a single ETag is supported in If-Match!", http.StatusBadRequest)
+		return 0, false
+	}
+
+	// If-Match использует строгое сравнение, поэтому слабый или чужой ETag не совпадает ни с одной версией
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(ifMatch, `"`), `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(ifMatch, `"`) || !strings.HasSuffix(ifMatch, `"`) {
+		logrus.WithField("If-Match", ifMatch).Warn("If-Match doesn't match any song version")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return version, true
+}
+
+// isNotModified проверяет условные заголовки запроса. If-None-Match имеет приоритет над If-Modified-Since (RFC 9110)
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.After(since)
+	}
+	return false
+}
+
 // @Summary Get lyrics of a song
 // @Description Get lyrics of a song by id with pagination
 // @Tags songs
@@ -106,14 +231,30 @@ func GetSongLyrics(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Add a new song
-// @Description Adds a new song to the library. The request body must be in JSON format and include the song's title and group
+// @Description Adds a new song to the library. The request body must be in JSON format and include the song's title and group. Responds with the created song enriched by the metadata providers (METADATA_PROVIDERS: the side API and/or a local catalogue). The sources field tells where releaseDate, lyrics and link were taken from: a provider name or client.
+// @Description If the artist already has a song with the same title (case, punctuation and extra spaces are ignored), responds with 409 pointing at the existing song. onConflict=skip returns the existing song instead, onConflict=update fills it with fresh non-empty releaseDate, lyrics and link, fields without new data are kept.
+// @Description In async mode (async=true or ENRICHMENT_ASYNC=true) the song is saved at once without side API data and the response is 202. The data is filled in the background, the progress is available at /songs/{id}/enrichment.
+// @Description If the side API fails, the song is rejected by default. With onEnrichmentError=store (or ENRICHMENT_ERROR_POLICY=store) a new song is saved with releaseDate, lyrics and link from the request body. After a temporary failure (timeout, 5xx, open circuit) the song stays pending and is enriched by background workers; a song unknown to the providers is saved as failed and can be re-enriched via POST /songs/{id}/enrichment
 // @Accept  json
-// @Param song body entities.Song true "Song object containing title and group"
-// @Success 201 "Song created successfully"
-// @Failure 400 {string} string "Invalid request body"
+// @Produce  json
+// @Param song body entities.Song true "Song object containing title and group. releaseDate in format DD.MM.YYYY, lyrics and link are optional and are used only if the side API fails"
+// @Param onConflict query string false "What to do if the song already exists" Enums(skip, update)
+// @Param async query bool false "Enrich the song in the background. Defaults to ENRICHMENT_ASYNC from config"
+// @Param onEnrichmentError query string false "What to do if the side API fails. Defaults to ENRICHMENT_ERROR_POLICY from config or reject" Enums(reject, store)
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 200 {object} entities.Song "Song already existed and was skipped or updated"
+// @Success 201 {object} entities.Song "Song created successfully"
+// @Success 202 {object} entities.Song "Song saved and will be enriched in the background. With onConflict=update the existing song is queued for enrichment and the response is entities.SongEnrichment"
+// @Header 201 {string} Location "Path of the created song"
+// @Header 201 {string} ETag "Version of the created song"
+// @Failure 400 {string} string "Invalid request body or onConflict parameter"
+// @Failure 409 {string} string "Song already exists, Location header points at it"
 // @Failure 415 {string} string "Unsupported Media Type"
-// @Failure 422 {string} string "Incorrect song data provided or has invalid format"
+// @Failure 422 {string} string "Incorrect song data provided or has invalid format, or no metadata provider knows the song"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 502 {string} string "Song details service failed"
+// @Failure 503 {string} string "Song details service is considered down, see Retry-After"
+// @Failure 504 {string} string "Song details service didn't respond in time"
 // @Router /songs [post]
 func AddSong(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Add song request received")
@@ -123,15 +264,36 @@ func AddSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	onConflict := r.URL.Query().Get("onConflict")
+	if onConflict != "" && onConflict != songConflictSkip && onConflict != songConflictUpdate {
+		logrus.WithField("onConflict", onConflict).Warn("Invalid onConflict provided")
+		http.Error(w, "Invalid onConflict! Use skip or update.", http.StatusBadRequest)
+		return
+	}
+
+	async, err := enrichmentAsync(r)
+	if err != nil {
+		logrus.WithField("async", r.URL.Query().Get("async")).Warn("Invalid async provided")
+		http.Error(w, "Invalid async! Use true or false.", http.StatusBadRequest)
+		return
+	}
+
+	onEnrichmentError := enrichmentErrorPolicy(r)
+	if onEnrichmentError != enrichmentErrorReject && onEnrichmentError != enrichmentErrorStore {
+		logrus.WithField("onEnrichmentError", onEnrichmentError).Warn("Invalid onEnrichmentError provided")
+		http.Error(w, "Invalid onEnrichmentError! Use reject or store.", http.StatusBadRequest)
+		return
+	}
+
 	var song entities.Song
-	err := json.NewDecoder(r.Body).Decode(&song)
+	err = json.NewDecoder(r.Body).Decode(&song)
 	if err != nil {
 		logrus.WithField("err", err).Error("Decoding body JSON error")
 		http.Error(w, "Invalid request body!", http.StatusBadRequest)
 		return
 	}
 
-	if song.Title == "" || song.Group == "" {
+	if song.Title == "" || entities.CleanArtistName(song.Group) == "" {
 		logrus.WithFields(logrus.Fields{
 			"title": song.Title,
 			"group": song.Group,
@@ -140,60 +302,68 @@ func AddSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	api := os.Getenv("API_URL")
-	url := fmt.Sprintf("http://%s/info?group=%s&song=%s", api, url.QueryEscape(song.Group), url.QueryEscape(song.Title))
-	logrus.WithFields(logrus.Fields{
-		"title": song.Title,
-		"group": song.Group,
-		"url":   url,
-	}).Debug("Fetching song data from side API")
-	resp, err := http.Get(url)
-	if err != nil {
+	// поля, переданные клиентом, сохраняются в асинхронном режиме и если сторонний API не вернул данные,
+	// поэтому проверяются до выбора режима: дата - так же, как при изменении песни, ссылка - чтобы она поместилась в бд
+	if !checkReleaseDate(w, &song.ReleaseDate) || !checkLink(w, song.Link) {
+		return
+	}
+
+	// дубликат проверяется до обращения к стороннему API, чтобы не запрашивать данные для песни, которая не будет добавлена
+	existingId, err := models.FindSong(song.Title, song.Group)
+	if err != nil && !errors.Is(err, models.ErrNoSongFound) {
 		logrus.WithFields(logrus.Fields{
 			"group": song.Group,
 			"title": song.Title,
 			"error": err,
-		}).Error("Error fetching song data from side API")
+		}).Error("Error checking song duplicate")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
+	exists := err == nil
+	if exists && onConflict != songConflictUpdate {
+		writeExistingSong(w, existingId, onConflict)
+		return
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		logrus.WithFields(logrus.Fields{
-			"group":  song.Group,
-			"title":  song.Title,
-			"status": resp.StatusCode,
-		}).Error("Side API responsed with error")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if async {
+		addSongAsync(w, &song, existingId, onConflict, r.Header.Get("X-Actor"))
 		return
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&song)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"group": song.Group,
-			"title": song.Title,
-			"error": err,
-		}).Error("Error decoding response")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	// без данных API сохраняется только новая песня: перезаписать существующую нечем
+	enrichErr := enrichSong(r.Context(), &song)
+	if enrichErr != nil && (exists || onEnrichmentError != enrichmentErrorStore || errors.Is(enrichErr, context.Canceled)) {
+		writeEnrichmentError(w, &song, enrichErr)
 		return
 	}
 
-	releaseDate, err := time.Parse("02.01.2006", song.ReleaseDate)
-	if err != nil {
+	if exists {
+		updateExistingSong(w, existingId, &song, r.Header.Get("X-Actor"))
+		return
+	}
+
+	if enrichErr != nil {
 		logrus.WithFields(logrus.Fields{
 			"group": song.Group,
 			"title": song.Title,
-			"error": err,
-		}).Error("Error parsing date from API response")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+			"error": enrichErr,
+		}).Warn("Side API has no song data, song is stored with provided fields only")
+		song.Sources = models.ClientSources(&song)
+		err = models.AddSongWithoutDetails(&song, r.Header.Get("X-Actor"), enrichErr.Error(), enrichmentRetryable(enrichErr))
+	} else {
+		err = models.AddSong(&song, r.Header.Get("X-Actor"))
 	}
-	song.ReleaseDate = releaseDate.Format("2006-01-02")
-
-	err = models.AddSong(&song)
-	if err != nil {
+	if err != nil && errors.Is(err, models.ErrSongExists) {
+		// такую же песню успели добавить параллельным запросом, пока шло обращение к стороннему API
+		if onConflict == songConflictUpdate && enrichErr == nil {
+			updateExistingSong(w, song.Id, &song, r.Header.Get("X-Actor"))
+		} else if onConflict == songConflictUpdate {
+			writeEnrichmentError(w, &song, enrichErr)
+		} else {
+			writeExistingSong(w, song.Id, onConflict)
+		}
+		return
+	} else if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"group": song.Group,
 			"title": song.Title,
@@ -204,10 +374,60 @@ func AddSong(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"group": song.Group,
-		"title": song.Title,
+		"song_id": song.Id,
+		"group":   song.Group,
+		"title":   song.Title,
 	}).Info("Song successfully added")
-	w.WriteHeader(http.StatusCreated)
+
+	if enrichErr != nil && enrichmentRetryable(enrichErr) {
+		wakeEnrichmentWorkers()
+	}
+	writeSong(w, http.StatusCreated, &song)
+}
+
+// checkReleaseDate переводит дату выхода из запроса в формат бд. Если дата некорректна, отвечает 422 и возвращает false
+func checkReleaseDate(w http.ResponseWriter, releaseDate *string) bool {
+	date, err := models.ParseReleaseDate(*releaseDate)
+	if err != nil {
+		logrus.WithField("releaseDate", *releaseDate).Warn("Invalid releaseDate provided")
+		http.Error(w, "Incorrect releaseDate! Please use format DD.MM.YYYY!", http.StatusUnprocessableEntity)
+		return false
+	}
+	*releaseDate = date
+	return true
+}
+
+// validLink проверяет, что ссылка на песню - абсолютный http(s) адрес, который помещается в столбец songs.link. Пустая ссылка допустима
+func validLink(link string) bool {
+	if link == "" {
+		return true
+	}
+	if utf8.RuneCountInString(link) > maxLinkLength {
+		return false
+	}
+	parsed, err := url.Parse(link)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// checkLink отвечает 422 и возвращает false, если ссылка некорректна
+func checkLink(w http.ResponseWriter, link string) bool {
+	if !validLink(link) {
+		logrus.WithField("link", link).Warn("Invalid link provided")
+		http.Error(w, fmt.Sprintf("Incorrect link! Please provide an http(s) URL of at most %d characters!", maxLinkLength), http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
+
+// writeSong отправляет песню вместе с ее адресом и версией
+func writeSong(w http.ResponseWriter, status int, song *entities.Song) {
+	w.Header().Set("Location", fmt.Sprintf("/songs/%d", song.Id))
+	w.Header().Set("ETag", songETag(song.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(song); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+	}
 }
 
 // @Summary Update an existing song
@@ -216,11 +436,16 @@ func AddSong(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Param id path int true "Song id"
 // @Param song body entities.Song true "Song object that needs to be updated"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Param If-Match header string false "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true"
 // @Success 204 "Successfully updated"
+// @Header 204 {string} ETag "New version of the song"
 // @Failure 400 {string} string "Invalid request body or song id"
 // @Failure 404 {string} string "No song found with the provided id"
+// @Failure 412 {string} string "Song was modified since the version in If-Match"
 // @Failure 415 {string} string "Unsupported Content-Type"
 // @Failure 422 {string} string "Incorrect body data provided or has invalid format"
+// @Failure 428 {string} string "If-Match header is required"
 // @Failure 500 {string} string "Internal server error"
 // @Router /songs/{id} [put]
 func UpdateSong(w http.ResponseWriter, r *http.Request) {
@@ -242,6 +467,11 @@ func UpdateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var song entities.Song
 	if err := json.NewDecoder(r.Body).Decode(&song); err != nil {
 		logrus.WithField("err", err).Error("Decoding body JSON error")
@@ -249,7 +479,7 @@ func UpdateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if song.Title == "" || song.Group == "" || song.ReleaseDate == "" || song.Lyrics == "" || song.Link == "" {
+	if song.Title == "" || entities.CleanArtistName(song.Group) == "" || song.ReleaseDate == "" || song.Lyrics == "" || song.Link == "" {
 		logrus.WithFields(logrus.Fields{
 			"title":       song.Title,
 			"group":       song.Group,
@@ -261,13 +491,9 @@ func UpdateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	releaseDate, err := time.Parse("02.01.2006", song.ReleaseDate)
-	if err != nil {
-		logrus.WithField("releaseDate", song.ReleaseDate).Warn("Invalid release date provided")
-		http.Error(w, "Incorrect releaseDate! Please use format DD.MM.YYYY!", http.StatusUnprocessableEntity)
+	if !checkReleaseDate(w, &song.ReleaseDate) {
 		return
 	}
-	song.ReleaseDate = releaseDate.Format("2006-01-02")
 
 	logrus.WithFields(logrus.Fields{
 		"song_id":     id,
@@ -277,11 +503,18 @@ func UpdateSong(w http.ResponseWriter, r *http.Request) {
 		"link":        song.Link,
 	}).Debug("Trying update song")
 
-	err = models.UpdateSong(id, &song)
+	err = models.UpdateSong(id, &song, r.Header.Get("X-Actor"), version)
 	if err != nil && errors.Is(err, models.ErrNoSongFound) {
 		logrus.WithField("song_id", id).Warn("No song with provided id")
 		http.Error(w, "No song with such id!", http.StatusNotFound)
 		return
+	} else if err != nil && errors.Is(err, models.ErrVersionMismatch) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"if_match": version,
+		}).Warn("Song version mismatch")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return
 	} else if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"song_id": id,
@@ -291,28 +524,43 @@ func UpdateSong(w http.ResponseWriter, r *http.Request) {
 		return
 	} else {
 		logrus.WithField("song_id", id).Info("Song updated successfully")
+		w.Header().Set("ETag", songETag(song.Version))
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
 // @Summary Patch song
-// @Description Partially updates an existing song by its id. At least one field (title, group, releaseDate, lyrics, or link) must be provided for update.
+// @Description Partially updates an existing song by its id. Three body formats are supported depending on Content-Type:
+// @Description application/json - only non-empty fields are updated, at least one field (title, group, releaseDate, lyrics, or link) must be provided;
+// @Description application/merge-patch+json - JSON Merge Patch (RFC 7396), absent fields are kept, null clears releaseDate, lyrics or link;
+// @Description application/json-patch+json - JSON Patch (RFC 6902) array of add, remove, replace, move, copy and test operations on the same fields.
 // @Tags songs
 // @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
 // @Param id path int true "Song id"
 // @Param song body entities.Song true "Fields to update in the song. At least one of: title, group, releaseDate, lyrics, or link."
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Param If-Match header string false "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true"
 // @Success 204 "Successfully patched"
+// @Header 204 {string} ETag "New version of the song"
 // @Failure 400 {string} string "Invalid request body or song id"
 // @Failure 415 {string} string "Unsupported Content-Type"
 // @Failure 422 {string} string "Incorrect body data provided or has invalid format"
 // @Failure 404 {string} string "No song with such id"
+// @Failure 409 {string} string "JSON Patch test operation failed"
+// @Failure 412 {string} string "Song was modified since the version in If-Match"
+// @Failure 428 {string} string "If-Match header is required"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /songs/{id} [patch]
 func PatchSong(w http.ResponseWriter, r *http.Request) {
 	logrus.Info("Patching song request received")
 
-	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+	ct := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(ct)
+	if mediaType != "application/json" && mediaType != mergePatchMediaType && mediaType != jsonPatchMediaType {
 		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		w.Header().Set("Accept-Patch", strings.Join([]string{"application/json", mergePatchMediaType, jsonPatchMediaType}, ", "))
 		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
 		return
 	}
@@ -328,6 +576,16 @@ func PatchSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	if mediaType != "application/json" {
+		patchSongDocument(w, r, id, version, mediaType)
+		return
+	}
+
 	var song entities.Song
 	err = json.NewDecoder(r.Body).Decode(&song)
 	if err != nil {
@@ -342,14 +600,14 @@ func PatchSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if song.ReleaseDate != "" {
-		releaseDate, err := time.Parse("02.01.2006", song.ReleaseDate)
-		if err != nil {
-			logrus.WithField("releaseDate", song.ReleaseDate).Warn("Invalid releaseDate provided")
-			http.Error(w, "Incorrect releaseDate! Please use format DD.MM.YYYY!", http.StatusUnprocessableEntity)
-			return
-		}
-		song.ReleaseDate = releaseDate.Format("2006-01-02")
+	if song.Group != "" && entities.CleanArtistName(song.Group) == "" {
+		logrus.WithField("group", song.Group).Warn("Blank group provided")
+		http.Error(w, "Incorrect group! Group can't consist of spaces only!", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !checkReleaseDate(w, &song.ReleaseDate) {
+		return
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -361,11 +619,18 @@ func PatchSong(w http.ResponseWriter, r *http.Request) {
 		"link":        song.Link,
 	}).Debug("Trying patching song")
 
-	err = models.PatchSong(id, &song)
+	err = models.PatchSong(id, &song, r.Header.Get("X-Actor"), version)
 	if err != nil && errors.Is(err, models.ErrNoSongFound) {
 		logrus.WithField("song_id", id).Warn("No song with provided id")
 		http.Error(w, "No song with such id!", http.StatusNotFound)
 		return
+	} else if err != nil && errors.Is(err, models.ErrVersionMismatch) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"if_match": version,
+		}).Warn("Song version mismatch")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return
 	} else if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"song_id": id,
@@ -375,17 +640,25 @@ func PatchSong(w http.ResponseWriter, r *http.Request) {
 		return
 	} else {
 		logrus.WithField("song_id", id).Info("Song successfully patched")
+		w.Header().Set("ETag", songETag(song.Version))
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
 // @Summary Delete a song
-// @Description Delete a song by id
+// @Description Moves a song to the trash by id. Songs in the trash are purged after TRASH_RETENTION_DAYS days and can be restored until then. With permanent=true the song is deleted at once, including songs already in the trash.
+// @Description If the song is in a playlist, it is either removed from playlists or the deletion is refused depending on PLAYLIST_SONG_DELETE config
 // @Tags songs
 // @Param id path int true "Song id"
+// @Param permanent query bool false "Delete the song permanently instead of moving it to the trash"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Param If-Match header string false "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true"
 // @Success 204 "Successfully deleted"
 // @Failure 400 {string} string "Invalid song id"
 // @Failure 404 {string} string "No song with such id"
+// @Failure 409 {string} string "Song is in a playlist"
+// @Failure 412 {string} string "Song was modified since the version in If-Match"
+// @Failure 428 {string} string "If-Match header is required"
 // @Failure 500 {string} string "Internal server error"
 // @Router /songs/{id} [delete]
 func DeleteSong(w http.ResponseWriter, r *http.Request) {
@@ -402,13 +675,47 @@ func DeleteSong(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logrus.WithField("song_id", id).Debug("Trying deleting song")
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
 
-	err = models.DeleteSong(id)
+	permanentStr := r.URL.Query().Get("permanent")
+	permanent := false
+	if permanentStr != "" {
+		permanent, err = strconv.ParseBool(permanentStr)
+		if err != nil {
+			logrus.WithField("permanent", permanentStr).Warn("Invalid permanent parameter provided")
+			http.Error(w, "Invalid permanent parameter provided!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id":   id,
+		"permanent": permanent,
+	}).Debug("Trying deleting song")
+
+	if permanent {
+		err = models.PurgeSong(id, r.Header.Get("X-Actor"), version)
+	} else {
+		err = models.DeleteSong(id, r.Header.Get("X-Actor"), version)
+	}
 	if err != nil && errors.Is(err, models.ErrNoSongFound) {
 		logrus.WithField("song_id", id).Warn("No song with provided id")
 		http.Error(w, "No song with such id!", http.StatusNotFound)
 		return
+	} else if err != nil && errors.Is(err, models.ErrSongInPlaylist) {
+		logrus.WithField("song_id", id).Warn("Can't delete song that is in a playlist")
+		http.Error(w, "Song is in a playlist! Remove it from playlists first.", http.StatusConflict)
+		return
+	} else if err != nil && errors.Is(err, models.ErrVersionMismatch) {
+		logrus.WithFields(logrus.Fields{
+			"song_id":  id,
+			"if_match": version,
+		}).Warn("Song version mismatch")
+		http.Error(w, "Song was modified by someone else! Get the song again and retry.", http.StatusPreconditionFailed)
+		return
 	} else if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"song_id": id,
@@ -417,7 +724,10 @@ func DeleteSong(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	} else {
-		logrus.WithField("song_id", id).Info("Song successfully deleted")
+		logrus.WithFields(logrus.Fields{
+			"song_id":   id,
+			"permanent": permanent,
+		}).Info("Song successfully deleted")
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
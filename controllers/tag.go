@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"EffectiveMobileTest/entities"
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// @Summary Get song tags
+// @Description Get genres and tags of a song
+// @Tags tags
+// @Produce  json
+// @Param id path int true "Song id"
+// @Success 200 {array} entities.Tag "Successfully fetched song tags"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song with such id"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/tags [get]
+func GetSongTags(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get song tags request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.GetSongTags(id)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error fetching song tags")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"tags":    len(tags),
+	}).Info("Fetched song tags successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&tags); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Attach a tag to a song
+// @Description Attaches a genre or a free-form tag to a song. The tag is created if it doesn't exist yet. Tag names are case-insensitive
+// @Tags tags
+// @Accept json
+// @Param id path int true "Song id"
+// @Param tag body entities.Tag true "Tag name and kind (genre or tag, defaults to tag)"
+// @Success 204 "Tag successfully attached"
+// @Failure 400 {string} string "Invalid request body or song id"
+// @Failure 404 {string} string "No song with such id"
+// @Failure 409 {string} string "Tag with such name already exists with another kind"
+// @Failure 415 {string} string "Unsupported Content-Type"
+// @Failure 422 {string} string "Incorrect tag data provided or tag name is longer than 64 characters"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/tags [post]
+func AttachSongTag(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Attach song tag request received")
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		logrus.WithField("Content-Type", ct).Warn("Unsupported Content-Type provided")
+		http.Error(w, "Unsupported Content-Type!", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	var tag entities.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		logrus.WithField("err", err).Error("Decoding body JSON error")
+		http.Error(w, "Invalid request body!", http.StatusBadRequest)
+		return
+	}
+
+	if tag.Kind == "" {
+		tag.Kind = models.TagKindTag
+	}
+	if models.NormalizeTagName(tag.Name) == "" || (tag.Kind != models.TagKindTag && tag.Kind != models.TagKindGenre) {
+		logrus.WithFields(logrus.Fields{
+			"name": tag.Name,
+			"kind": tag.Kind,
+		}).Warn("Invalid tag data")
+		http.Error(w, "Incorrect data provided!\nJSON should contain name and optional kind (genre or tag)!", http.StatusUnprocessableEntity)
+		return
+	}
+	if utf8.RuneCountInString(models.NormalizeTagName(tag.Name)) > models.MaxTagNameLength {
+		logrus.WithField("name", tag.Name).Warn("Too long tag name provided")
+		http.Error(w, fmt.Sprintf("Incorrect tag name! At most %d characters are allowed.", models.MaxTagNameLength), http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = models.AttachSongTag(id, &tag)
+	if err != nil && errors.Is(err, models.ErrNoSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id")
+		http.Error(w, "No song with such id!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.Is(err, models.ErrTagKindClash) {
+		logrus.WithFields(logrus.Fields{
+			"name": tag.Name,
+			"kind": tag.Kind,
+		}).Warn("Tag exists with another kind")
+		http.Error(w, "Tag with such name already exists with another kind!", http.StatusConflict)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"tag":     tag.Name,
+			"error":   err,
+		}).Error("Error attaching tag")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"tag":     tag.Name,
+	}).Info("Tag successfully attached")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Detach a tag from a song
+// @Description Detaches a genre or a tag from a song by tag name
+// @Tags tags
+// @Param id path int true "Song id"
+// @Param tag path string true "Tag name"
+// @Success 204 "Tag successfully detached"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "Song has no such tag"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/tags/{tag} [delete]
+func DetachSongTag(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Detach song tag request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+	tag := vars["tag"]
+
+	err = models.DetachSongTag(id, tag)
+	if err != nil && errors.Is(err, models.ErrNoTagFound) {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"tag":     tag,
+		}).Warn("Song has no such tag")
+		http.Error(w, "Song has no such tag!", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"tag":     tag,
+			"error":   err,
+		}).Error("Error detaching tag")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"song_id": id,
+		"tag":     tag,
+	}).Info("Tag successfully detached")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get tag cloud
+// @Description Get tags with number of songs, most used first. Tags without songs are not returned
+// @Tags tags
+// @Produce  json
+// @Param kind query string false "Filter by tag kind: genre or tag"
+// @Success 200 {array} entities.TagCount "Successfully fetched tag counts"
+// @Failure 400 {string} string "Invalid kind parameter"
+// @Failure 500 {string} string "Internal server error"
+// @Router /tags [get]
+func GetTagCounts(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get tag counts request received")
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != models.TagKindTag && kind != models.TagKindGenre {
+		logrus.WithField("kind", kind).Warn("Invalid kind parameter provided")
+		http.Error(w, "Invalid kind parameter provided! Use genre or tag.", http.StatusBadRequest)
+		return
+	}
+
+	counts, err := models.GetTagCounts(kind)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"kind":  kind,
+			"error": err,
+		}).Error("Error fetching tag counts")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("tags", len(counts)).Info("Fetched tag counts successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&counts); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
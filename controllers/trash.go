@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"EffectiveMobileTest/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// @Summary Get trash
+// @Description Get deleted songs that are still in the trash with pagination, most recently deleted first. Every song contains the time it will be purged at
+// @Tags trash
+// @Produce  json
+// @Param page query int true "Page number"
+// @Param songsPerPage query int true "Number of songs per page"
+// @Success 200 {array} entities.TrashedSong "Successfully fetched trash"
+// @Failure 400 {string} string "One of query parameters is invalid"
+// @Failure 500 {string} string "Internal server error"
+// @Router /trash [get]
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Get trash request received")
+	pageStr := r.URL.Query().Get("page")
+	songsPerPageStr := r.URL.Query().Get("songsPerPage")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		logrus.WithField("page", pageStr).Warn("Invalid page parameter provided")
+		http.Error(w, "Invalid page parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	songsPerPage, err := strconv.Atoi(songsPerPageStr)
+	if err != nil || songsPerPage < 1 {
+		logrus.WithField("songsPerPage", songsPerPageStr).Warn("Invalid songsPerPage parameter provided")
+		http.Error(w, "Invalid songsPerPage parameter provided!", http.StatusBadRequest)
+		return
+	}
+
+	trash, err := models.GetTrash(songsPerPage, (page-1)*songsPerPage)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"page":         page,
+			"songsPerPage": songsPerPage,
+			"error":        err,
+		}).Error("Error fetching trash")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"page":  page,
+		"songs": len(trash),
+	}).Info("Fetched trash successfully")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&trash); err != nil {
+		logrus.WithField("error", err).Error("Error encoding response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// @Summary Restore a deleted song
+// @Description Returns a song from the trash to the library. The restore is saved in the song revision history
+// @Tags trash
+// @Param id path int true "Song id"
+// @Param X-Actor header string false "Name of the editor saved in the song revision history"
+// @Success 204 "Successfully restored"
+// @Failure 400 {string} string "Invalid song id"
+// @Failure 404 {string} string "No song with such id in the trash"
+// @Failure 409 {string} string "The artist already has a song with such title, Location header points at it"
+// @Failure 500 {string} string "Internal server error"
+// @Router /songs/{id}/restore [post]
+func RestoreSong(w http.ResponseWriter, r *http.Request) {
+	logrus.Info("Restore song request received")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil || id < 1 {
+		logrus.WithField("id", vars["id"]).Warn("Invalid song id provided")
+		http.Error(w, "Invalid song id!", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RestoreSong(id, r.Header.Get("X-Actor"))
+	var existsErr *models.SongExistsError
+	if err != nil && errors.Is(err, models.ErrNoTrashedSongFound) {
+		logrus.WithField("song_id", id).Warn("No song with provided id in trash")
+		http.Error(w, "No song with such id in the trash!", http.StatusNotFound)
+		return
+	} else if err != nil && errors.As(err, &existsErr) {
+		writeExistingSong(w, existsErr.SongId, "")
+		return
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"song_id": id,
+			"error":   err,
+		}).Error("Error restoring song")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithField("song_id", id).Info("Song restored successfully")
+	w.WriteHeader(http.StatusNoContent)
+}
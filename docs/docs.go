@@ -15,50 +15,17 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/library": {
+        "/admin/duplicates": {
             "get": {
-                "description": "Retrieve songs from the library with optional filters and pagination",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Get pairs of songs that are likely duplicates: songs of the same or a similar artist with the same title ignoring case, punctuation and extra spaces, or with similar titles by trigram similarity. Each song gets at most 10 candidates. Exact matches go first, then the most similar pairs",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "library"
+                    "admin"
                 ],
-                "summary": "Get songs library",
+                "summary": "Find duplicate songs",
                 "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Filter by song title",
-                        "name": "title",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by group name",
-                        "name": "group",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by release date (format DD.MM.YYYY)",
-                        "name": "releaseDate",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by lyrics",
-                        "name": "lyrics",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by link to clip",
-                        "name": "link",
-                        "in": "query"
-                    },
                     {
                         "type": "integer",
                         "description": "Page number",
@@ -68,19 +35,25 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "description": "Number of songs per page",
-                        "name": "songsPerPage",
+                        "description": "Number of pairs per page",
+                        "name": "pairsPerPage",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimal title and artist similarity from 0 to 1. Defaults to 0.6",
+                        "name": "threshold",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully fetched songs library",
+                        "description": "Successfully fetched duplicates",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/entities.Song"
+                                "$ref": "#/definitions/entities.DuplicatePair"
                             }
                         }
                     },
@@ -99,27 +72,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs": {
+        "/admin/duplicates/merge": {
             "post": {
-                "description": "Adds a new song to the library. The request body must be in JSON format and include the song's title and group",
+                "description": "Merges songs from mergeIds into the song keepId. The kept song gets the longest lyrics, the link and release date of merged songs if it has none, their tags, playlist entries and album track if it has no album. Merged songs are moved to the trash",
                 "consumes": [
                     "application/json"
                 ],
-                "summary": "Add a new song",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Merge duplicate songs",
                 "parameters": [
                     {
-                        "description": "Song object containing title and group",
-                        "name": "song",
+                        "description": "Song to keep and songs to merge into it",
+                        "name": "merge",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entities.Song"
+                            "$ref": "#/definitions/entities.MergeSongsRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Song created successfully"
+                    "200": {
+                        "description": "Merged song",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
                     },
                     "400": {
                         "description": "Invalid request body",
@@ -127,20 +115,26 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "404": {
+                        "description": "One of the songs is not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "415": {
-                        "description": "Unsupported Media Type",
+                        "description": "Unsupported Content-Type",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "422": {
-                        "description": "Incorrect song data provided or has invalid format",
+                        "description": "No songs to merge or a song is merged into itself",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
@@ -148,97 +142,185 @@ const docTemplate = `{
                 }
             }
         },
-        "/songs/{id}": {
-            "put": {
-                "description": "Update the details of a song by its id. The request body must be in JSON format and include all required fields: title, group, releaseDate, lyrics and link.",
-                "consumes": [
+        "/admin/song-info": {
+            "get": {
+                "description": "Get the state of the circuit breaker in front of the song details side API. While the circuit is open, songs are not enriched and POST /songs responds with 503",
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "admin"
                 ],
-                "summary": "Update an existing song",
+                "summary": "Get side API circuit breaker status",
+                "responses": {
+                    "200": {
+                        "description": "Circuit breaker status",
+                        "schema": {
+                            "$ref": "#/definitions/entities.CircuitStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Side API is not among METADATA_PROVIDERS",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/albums": {
+            "get": {
+                "description": "Get albums ordered by release date with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "albums"
+                ],
+                "summary": "Get albums",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Song id",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Filter by artist id",
+                        "name": "artistId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Song object that needs to be updated",
-                        "name": "song",
+                        "type": "integer",
+                        "description": "Number of albums per page",
+                        "name": "albumsPerPage",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched albums",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Album"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a new album. The artist is matched by name the same way as the group of a song and is created if missing",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "albums"
+                ],
+                "summary": "Add a new album",
+                "parameters": [
+                    {
+                        "description": "Album object containing title, artist and optional releaseDate (DD.MM.YYYY) and coverLink",
+                        "name": "album",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entities.Song"
+                            "$ref": "#/definitions/entities.Album"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Successfully updated"
-                    },
-                    "400": {
-                        "description": "Invalid request body or song id",
+                    "201": {
+                        "description": "Album created successfully",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/entities.Album"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the created album"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "No song found with the provided id",
+                    "400": {
+                        "description": "Invalid request body",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "415": {
-                        "description": "Unsupported Content-Type",
+                        "description": "Unsupported Media Type",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "422": {
-                        "description": "Incorrect body data provided or has invalid format",
+                        "description": "Incorrect album data provided or has invalid format",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a song by id",
+            }
+        },
+        "/albums/{id}": {
+            "get": {
+                "description": "Get album by id",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "songs"
+                    "albums"
                 ],
-                "summary": "Delete a song",
+                "summary": "Get an album",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Song id",
+                        "description": "Album id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Successfully deleted"
+                    "200": {
+                        "description": "Successfully fetched album",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Album"
+                        }
                     },
                     "400": {
-                        "description": "Invalid song id",
+                        "description": "Invalid album id",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "No song with such id",
+                        "description": "No album with such id",
                         "schema": {
                             "type": "string"
                         }
@@ -251,45 +333,45 @@ const docTemplate = `{
                     }
                 }
             },
-            "patch": {
-                "description": "Partially updates an existing song by its id. At least one field (title, group, releaseDate, lyrics, or link) must be provided for update.",
+            "put": {
+                "description": "Update album by id. The request body must contain title and artist, releaseDate (DD.MM.YYYY) and coverLink are optional",
                 "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "songs"
+                    "albums"
                 ],
-                "summary": "Patch song",
+                "summary": "Update an album",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Song id",
+                        "description": "Album id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Fields to update in the song. At least one of: title, group, releaseDate, lyrics, or link.",
-                        "name": "song",
+                        "description": "Album object that needs to be updated",
+                        "name": "album",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entities.Song"
+                            "$ref": "#/definitions/entities.Album"
                         }
                     }
                 ],
                 "responses": {
                     "204": {
-                        "description": "Successfully patched"
+                        "description": "Successfully updated"
                     },
                     "400": {
-                        "description": "Invalid request body or song id",
+                        "description": "Invalid request body or album id",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "No song with such id",
+                        "description": "No album with such id",
                         "schema": {
                             "type": "string"
                         }
@@ -301,68 +383,52 @@ const docTemplate = `{
                         }
                     },
                     "422": {
-                        "description": "Incorrect body data provided or has invalid format",
+                        "description": "Incorrect album data provided or has invalid format",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            }
-        },
-        "/songs/{id}/lyrics": {
-            "get": {
-                "description": "Get lyrics of a song by id with pagination",
-                "produces": [
-                    "application/json"
-                ],
+            },
+            "delete": {
+                "description": "Delete an album by id. Songs of the album stay in the library",
                 "tags": [
-                    "songs"
+                    "albums"
                 ],
-                "summary": "Get lyrics of a song",
+                "summary": "Delete an album",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Song id",
+                        "description": "Album id",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Number of verses per page",
-                        "name": "versesPerPage",
-                        "in": "query",
-                        "required": true
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Successfully fetched lyrics",
-                        "schema": {
-                            "$ref": "#/definitions/entities.SongVerses"
-                        }
+                    "204": {
+                        "description": "Successfully deleted"
                     },
                     "400": {
-                        "description": "One of parameters is invalid or not provided",
+                        "description": "Invalid album id",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "No song with such id",
+                        "description": "No album with such id",
                         "schema": {
                             "type": "string"
                         }
@@ -375,33 +441,2848 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "entities.Song": {
-            "type": "object",
-            "properties": {
-                "group": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "link": {
-                    "type": "string"
-                },
-                "lyrics": {
-                    "type": "string"
-                },
-                "releaseDate": {
-                    "type": "string"
-                },
-                "title": {
-                    "type": "string"
-                }
-            }
         },
-        "entities.SongVerses": {
+        "/albums/{id}/songs": {
+            "get": {
+                "description": "Get songs of the album ordered by track number",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "albums"
+                ],
+                "summary": "Get album songs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Album id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched album songs",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid album id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No album with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Puts the song into the album under the given track number. A song that belongs to another album is moved",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "albums"
+                ],
+                "summary": "Put a song into an album",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Album id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Song id and track number",
+                        "name": "track",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AlbumTrack"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Song successfully put into the album"
+                    },
+                    "400": {
+                        "description": "Invalid request body or album id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No album or song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Track number is already taken",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect song id or track number",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/albums/{id}/songs/{songId}": {
+            "delete": {
+                "description": "Removes the song from the album. The song itself stays in the library",
+                "tags": [
+                    "albums"
+                ],
+                "summary": "Remove a song from an album",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Album id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "songId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Song successfully removed from the album"
+                    },
+                    "400": {
+                        "description": "Invalid album or song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song is not in the album",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/artists": {
+            "get": {
+                "description": "Get artists ordered by name with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artists"
+                ],
+                "summary": "Get artists",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by artist name prefix (case-insensitive)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of artists per page",
+                        "name": "artistsPerPage",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched artists",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Artist"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a new artist. Artist names are compared case-insensitively ignoring extra spaces",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artists"
+                ],
+                "summary": "Add a new artist",
+                "parameters": [
+                    {
+                        "description": "Artist object containing name and optional description",
+                        "name": "artist",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Artist"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Artist created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Artist"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the created artist"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Artist with such name already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Artist name not provided",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/artists/{id}": {
+            "get": {
+                "description": "Get artist by id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artists"
+                ],
+                "summary": "Get an artist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Artist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched artist",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Artist"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid artist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No artist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update artist name and description by id. Group of all artist's songs is renamed too",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "artists"
+                ],
+                "summary": "Update an artist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Artist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Artist object that needs to be updated",
+                        "name": "artist",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Artist"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully updated"
+                    },
+                    "400": {
+                        "description": "Invalid request body or artist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No artist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another artist with such name already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Artist name not provided",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete an artist by id. Artists having songs can't be deleted",
+                "tags": [
+                    "artists"
+                ],
+                "summary": "Delete an artist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Artist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "400": {
+                        "description": "Invalid artist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No artist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Artist has songs, including songs in the trash that are listed in the message, or albums",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/library": {
+            "get": {
+                "description": "Retrieve songs from the library with optional filters and pagination.\nTwo pagination modes are supported: page mode (page and songsPerPage) returns an array of songs,\ncursor mode (cursor and songsPerPage) returns entities.LibraryPage with items and nextCursor. Pass an empty cursor to get the first page in cursor mode.\nWith q parameter the songs are returned as entities.LyricsSearchHit with rank and snippet fields",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "library"
+                ],
+                "summary": "Get songs library",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by song title",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by release date (format DD.MM.YYYY)",
+                        "name": "releaseDate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter songs released on or after the date (format DD.MM.YYYY)",
+                        "name": "releasedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter songs released on or before the date (format DD.MM.YYYY)",
+                        "name": "releasedTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter songs released in the year",
+                        "name": "releaseYear",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by lyrics",
+                        "name": "lyrics",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text search in lyrics (web search syntax: quoted phrases, OR, -word). Results are ranked by relevance unless sort is provided and each song gets a highlighted snippet. Can't be used with cursor",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Alias for q",
+                        "name": "lyricsQuery",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by link to clip",
+                        "name": "link",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated sort fields: id, title, group, releaseDate. Prefix a field with '-' for descending order, e.g. -releaseDate,title",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number. Required unless cursor is provided",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor returned as nextCursor by the previous request. Alternative to page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of songs per page",
+                        "name": "songsPerPage",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by album id",
+                        "name": "album",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated list of genres or tags",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "any (default) returns songs having at least one of tags, all returns songs having every tag",
+                        "name": "tagsMode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Typo-tolerant matching of title and group by trigram similarity instead of prefix search. Results are ordered by similarity unless sort is provided",
+                        "name": "fuzzy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimal similarity from 0 to 1 in fuzzy mode. Defaults to FUZZY_THRESHOLD from config or 0.3",
+                        "name": "threshold",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wrap the page into entities.LibraryEnvelope with total count and links to neighbouring pages. Used in page mode only",
+                        "name": "envelope",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched songs library. With cursor the page is returned as entities.LibraryPage object with items and nextCursor fields. With envelope=true the page is returned as entities.LibraryEnvelope object with items, total, page, songsPerPage, totalPages, prev and next fields. With q the songs are entities.LyricsSearchHit objects with additional rank and snippet fields, both as a plain array and inside the envelope",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/library/export": {
+            "get": {
+                "description": "Downloads the whole library or its part as a file. Accepts the same filters and sort as /library, but without pagination.\nSongs are streamed from the database row by row, so the export of a big library doesn't load it into memory",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "library"
+                ],
+                "summary": "Export songs library",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File format: csv, ndjson or json (default)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by song title",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by group name",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by release date (format DD.MM.YYYY)",
+                        "name": "releaseDate",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter songs released on or after the date (format DD.MM.YYYY)",
+                        "name": "releasedFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter songs released on or before the date (format DD.MM.YYYY)",
+                        "name": "releasedTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter songs released in the year",
+                        "name": "releaseYear",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by lyrics",
+                        "name": "lyrics",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Full-text search in lyrics (web search syntax)",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by link to clip",
+                        "name": "link",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated sort fields: id, title, group, releaseDate. Prefix a field with '-' for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Filter by album id",
+                        "name": "album",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated list of genres or tags",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "any (default) or all",
+                        "name": "tagsMode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Typo-tolerant matching of title and group",
+                        "name": "fuzzy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimal similarity from 0 to 1 in fuzzy mode",
+                        "name": "threshold",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Library file",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Song"
+                            }
+                        },
+                        "headers": {
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "Attachment with the file name, e.g. library-2024-01-31.csv"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/playlists": {
+            "get": {
+                "description": "Get playlists with number of songs in each with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Get playlists",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of playlists per page",
+                        "name": "playlistsPerPage",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched playlists",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Playlist"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates an empty playlist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Create a playlist",
+                "parameters": [
+                    {
+                        "description": "Playlist object containing name",
+                        "name": "playlist",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Playlist"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Playlist created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Playlist"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the created playlist"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Playlist name not provided",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/playlists/{id}": {
+            "get": {
+                "description": "Get playlist by id with its entries in order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Get a playlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched playlist",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Playlist"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid playlist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Rename a playlist by id. Entries of the playlist are not changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Rename a playlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Playlist object containing new name",
+                        "name": "playlist",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Playlist"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully renamed"
+                    },
+                    "400": {
+                        "description": "Invalid request body or playlist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Playlist name not provided",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a playlist by id. Songs stay in the library",
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Delete a playlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "400": {
+                        "description": "Invalid playlist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/playlists/{id}/entries": {
+            "post": {
+                "description": "Inserts a song into the playlist at the given position shifting the following entries. Without position the song is appended to the end",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Add a song to a playlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Entry containing songId and optional position",
+                        "name": "entry",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.PlaylistEntry"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Song successfully added",
+                        "schema": {
+                            "$ref": "#/definitions/entities.PlaylistEntry"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or playlist id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist or song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect song id or position",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/playlists/{id}/entries/{entryId}": {
+            "put": {
+                "description": "Moves the entry to a new position shifting entries in between. Position beyond the end moves the entry to the end",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Move a playlist entry",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entry id",
+                        "name": "entryId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Entry containing new position",
+                        "name": "entry",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.PlaylistEntry"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Entry successfully moved"
+                    },
+                    "400": {
+                        "description": "Invalid request body or id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist or entry with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect position",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the entry from the playlist shifting the following entries",
+                "tags": [
+                    "playlists"
+                ],
+                "summary": "Remove a song from a playlist",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Playlist id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entry id",
+                        "name": "entryId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Entry successfully removed"
+                    },
+                    "400": {
+                        "description": "Invalid id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No playlist or entry with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs": {
+            "post": {
+                "description": "Adds a new song to the library. The request body must be in JSON format and include the song's title and group. Responds with the created song enriched by the metadata providers (METADATA_PROVIDERS: the side API and/or a local catalogue). The sources field tells where releaseDate, lyrics and link were taken from: a provider name or client.\nIf the artist already has a song with the same title (case, punctuation and extra spaces are ignored), responds with 409 pointing at the existing song. onConflict=skip returns the existing song instead, onConflict=update fills it with fresh non-empty releaseDate, lyrics and link, fields without new data are kept.\nIn async mode (async=true or ENRICHMENT_ASYNC=true) the song is saved at once without side API data and the response is 202. The data is filled in the background, the progress is available at /songs/{id}/enrichment.\nIf the side API fails, the song is rejected by default. With onEnrichmentError=store (or ENRICHMENT_ERROR_POLICY=store) a new song is saved with releaseDate, lyrics and link from the request body. After a temporary failure (timeout, 5xx, open circuit) the song stays pending and is enriched by background workers; a song unknown to the providers is saved as failed and can be re-enriched via POST /songs/{id}/enrichment",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Add a new song",
+                "parameters": [
+                    {
+                        "description": "Song object containing title and group. releaseDate in format DD.MM.YYYY, lyrics and link are optional and are used only if the side API fails",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "skip",
+                            "update"
+                        ],
+                        "type": "string",
+                        "description": "What to do if the song already exists",
+                        "name": "onConflict",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Enrich the song in the background. Defaults to ENRICHMENT_ASYNC from config",
+                        "name": "async",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "reject",
+                            "store"
+                        ],
+                        "type": "string",
+                        "description": "What to do if the side API fails. Defaults to ENRICHMENT_ERROR_POLICY from config or reject",
+                        "name": "onEnrichmentError",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Song already existed and was skipped or updated",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    "201": {
+                        "description": "Song created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the created song"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the created song"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Song saved and will be enriched in the background. With onConflict=update the existing song is queued for enrichment and the response is entities.SongEnrichment",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or onConflict parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Song already exists, Location header points at it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect song data provided or has invalid format, or no metadata provider knows the song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Song details service failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Song details service is considered down, see Retry-After",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Song details service didn't respond in time",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}": {
+            "get": {
+                "description": "Get full song data by id. Response contains ETag and Last-Modified headers, so conditional requests with If-None-Match or If-Modified-Since are supported.\nETag is the version of the song, pass it in If-Match to PUT, PATCH or DELETE to make sure nobody changed the song in between",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched song",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    "304": {
+                        "description": "Song not modified"
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update the details of a song by its id. The request body must be in JSON format and include all required fields: title, group, releaseDate, lyrics and link.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Update an existing song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Song object that needs to be updated",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully updated",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song found with the provided id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Song was modified since the version in If-Match",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect body data provided or has invalid format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match header is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Moves a song to the trash by id. Songs in the trash are purged after TRASH_RETENTION_DAYS days and can be restored until then. With permanent=true the song is deleted at once, including songs already in the trash.\nIf the song is in a playlist, it is either removed from playlists or the deletion is refused depending on PLAYLIST_SONG_DELETE config",
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Delete a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete the song permanently instead of moving it to the trash",
+                        "name": "permanent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully deleted"
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Song is in a playlist",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Song was modified since the version in If-Match",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match header is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Partially updates an existing song by its id. Three body formats are supported depending on Content-Type:\napplication/json - only non-empty fields are updated, at least one field (title, group, releaseDate, lyrics, or link) must be provided;\napplication/merge-patch+json - JSON Merge Patch (RFC 7396), absent fields are kept, null clears releaseDate, lyrics or link;\napplication/json-patch+json - JSON Patch (RFC 6902) array of add, remove, replace, move, copy and test operations on the same fields.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Patch song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to update in the song. At least one of: title, group, releaseDate, lyrics, or link.",
+                        "name": "song",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the song from GET /songs/{id}. Required when REQUIRE_IF_MATCH=true",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully patched",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New version of the song"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "JSON Patch test operation failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Song was modified since the version in If-Match",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect body data provided or has invalid format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "428": {
+                        "description": "If-Match header is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/enrichment": {
+            "get": {
+                "description": "Get the state of filling the song with side API data. Songs added in async mode are pending until background workers enrich them",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get song enrichment status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Song enrichment status",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SongEnrichment"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song found with the provided id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Queues the song for enrichment by background workers, even if it is already enriched or enrichment failed. Fields are overwritten with non-empty side API data",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Re-enrich a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Song queued for enrichment",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SongEnrichment"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Path of the song enrichment status"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song found with the provided id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/lyrics": {
+            "get": {
+                "description": "Get lyrics of a song by id with pagination",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Get lyrics of a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of verses per page",
+                        "name": "versesPerPage",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched lyrics",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SongVerses"
+                        }
+                    },
+                    "400": {
+                        "description": "One of parameters is invalid or not provided",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/lyrics/diff": {
+            "get": {
+                "description": "Line-level diff of song lyrics between two revisions or between a revision and the current text. Lines are split into verses the same way as in /songs/{id}/lyrics, every hunk is annotated with the verse where changes start. Responds with JSON hunks by default or with a unified diff in text/plain when format=text or Accept: text/plain is requested",
+                "produces": [
+                    "application/json",
+                    "text/plain"
+                ],
+                "tags": [
+                    "revisions"
+                ],
+                "summary": "Diff song lyrics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision number or current",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision number or current, current by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json (default) or text",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully built lyrics diff",
+                        "schema": {
+                            "$ref": "#/definitions/entities.LyricsDiff"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id, revision or format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No such song or revision",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/restore": {
+            "post": {
+                "description": "Returns a song from the trash to the library. The restore is saved in the song revision history",
+                "tags": [
+                    "trash"
+                ],
+                "summary": "Restore a deleted song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully restored"
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "The artist already has a song with such title, Location header points at it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/revisions": {
+            "get": {
+                "description": "Get the history of song changes, newest first. Every revision contains a full snapshot of the song. History of a deleted song is available too",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revisions"
+                ],
+                "summary": "Get song revisions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched song revisions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.SongRevision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/revisions/{rev}": {
+            "get": {
+                "description": "Get a single revision of the song with full snapshot",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revisions"
+                ],
+                "summary": "Get a song revision",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "rev",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched song revision",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SongRevision"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id or revision number",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No such revision of the song",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/revisions/{rev}/restore": {
+            "post": {
+                "description": "Returns the song to the state of the given revision. The restore itself is saved as a new revision",
+                "tags": [
+                    "revisions"
+                ],
+                "summary": "Restore a song revision",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "rev",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Successfully restored"
+                    },
+                    "400": {
+                        "description": "Invalid song id or revision number",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No such song or revision",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another song of the artist already has the title of the revision, Location header points at it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/tags": {
+            "get": {
+                "description": "Get genres and tags of a song",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Get song tags",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched song tags",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Tag"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Attaches a genre or a free-form tag to a song. The tag is created if it doesn't exist yet. Tag names are case-insensitive",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Attach a tag to a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tag name and kind (genre or tag, defaults to tag)",
+                        "name": "tag",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.Tag"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Tag successfully attached"
+                    },
+                    "400": {
+                        "description": "Invalid request body or song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No song with such id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Tag with such name already exists with another kind",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Content-Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Incorrect tag data provided or tag name is longer than 64 characters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs/{id}/tags/{tag}": {
+            "delete": {
+                "description": "Detaches a genre or a tag from a song by tag name",
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Detach a tag from a song",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Song id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag name",
+                        "name": "tag",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Tag successfully detached"
+                    },
+                    "400": {
+                        "description": "Invalid song id",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Song has no such tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/songs:batch": {
+            "post": {
+                "description": "Adds many songs in one request. The body is either a JSON array of songs (Content-Type: application/json) or one song per line (Content-Type: application/x-ndjson).\nSongs are enriched by the side API concurrently by BATCH_WORKERS workers and saved one by one, so a failure of one song doesn't affect the others.\nSongs that already exist in the library or repeat earlier songs of the same batch are skipped. The response reports the status of every song.\nWith ENRICHMENT_ERROR_POLICY=store songs the side API has no data for are saved with releaseDate (DD.MM.YYYY), lyrics and link from the request; after temporary failures they stay pending and are enriched by background workers",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "songs"
+                ],
+                "summary": "Add songs in batch",
+                "parameters": [
+                    {
+                        "description": "Songs containing title and group",
+                        "name": "songs",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.Song"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the editor saved in the song revision history",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Batch processed, see status of every song",
+                        "schema": {
+                            "$ref": "#/definitions/entities.BatchReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many songs in one batch or the body is larger than BATCH_MAX_BYTES",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags": {
+            "get": {
+                "description": "Get tags with number of songs, most used first. Tags without songs are not returned",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Get tag cloud",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by tag kind: genre or tag",
+                        "name": "kind",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched tag counts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.TagCount"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid kind parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash": {
+            "get": {
+                "description": "Get deleted songs that are still in the trash with pagination, most recently deleted first. Every song contains the time it will be purged at",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "trash"
+                ],
+                "summary": "Get trash",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of songs per page",
+                        "name": "songsPerPage",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully fetched trash",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.TrashedSong"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "One of query parameters is invalid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "entities.Album": {
+            "type": "object",
+            "properties": {
+                "artist": {
+                    "type": "string"
+                },
+                "artistId": {
+                    "description": "заполняется сервером по artist",
+                    "type": "integer"
+                },
+                "coverLink": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "releaseDate": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.AlbumTrack": {
+            "type": "object",
+            "properties": {
+                "songId": {
+                    "type": "integer"
+                },
+                "trackNumber": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.Artist": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "id созданной песни или уже существующей песни, дубликатом которой оказалась эта",
+                    "type": "integer"
+                },
+                "index": {
+                    "description": "позиция песни в запросе, начиная с 0",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "created, duplicate, invalid, enrichment_failed или failed",
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.BatchReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.BatchItemResult"
+                    }
+                },
+                "skipped": {
+                    "description": "дубликаты",
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.CircuitStatus": {
+            "type": "object",
+            "properties": {
+                "failureThreshold": {
+                    "description": "после стольких неудач подряд автомат размыкается",
+                    "type": "integer"
+                },
+                "failures": {
+                    "description": "неудачных запросов подряд в состоянии closed",
+                    "type": "integer"
+                },
+                "openTimeout": {
+                    "description": "секунд в состоянии open до пробного запроса",
+                    "type": "number"
+                },
+                "openedAt": {
+                    "type": "string"
+                },
+                "retryAfter": {
+                    "description": "секунд до пробного запроса, если автомат разомкнут",
+                    "type": "number"
+                },
+                "state": {
+                    "description": "closed, open или half-open",
+                    "type": "string"
+                }
+            }
+        },
+        "entities.DuplicatePair": {
+            "type": "object",
+            "properties": {
+                "exact": {
+                    "description": "у песен один исполнитель и одинаковое нормализованное название",
+                    "type": "boolean"
+                },
+                "first": {
+                    "$ref": "#/definitions/entities.DuplicateSong"
+                },
+                "second": {
+                    "$ref": "#/definitions/entities.DuplicateSong"
+                },
+                "similarity": {
+                    "description": "похожесть названий от 0 до 1",
+                    "type": "number"
+                }
+            }
+        },
+        "entities.DuplicateSong": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "lyricsLength": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.LyricsDiff": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "номер ревизии или current",
+                    "type": "string"
+                },
+                "hunks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.LyricsDiffHunk"
+                    }
+                },
+                "songId": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.LyricsDiffHunk": {
+            "type": "object",
+            "properties": {
+                "fromLines": {
+                    "type": "integer"
+                },
+                "fromStart": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.LyricsDiffLine"
+                    }
+                },
+                "toLines": {
+                    "type": "integer"
+                },
+                "toStart": {
+                    "type": "integer"
+                },
+                "verse": {
+                    "description": "куплет, в котором начинаются изменения",
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.LyricsDiffLine": {
+            "type": "object",
+            "properties": {
+                "fromLine": {
+                    "type": "integer"
+                },
+                "fromVerse": {
+                    "description": "не заполняется для пустой строки между куплетами",
+                    "type": "integer"
+                },
+                "op": {
+                    "description": "context, delete или insert",
+                    "type": "string"
+                },
+                "text": {
+                    "type": "string"
+                },
+                "toLine": {
+                    "type": "integer"
+                },
+                "toVerse": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.MergeSongsRequest": {
+            "type": "object",
+            "properties": {
+                "keepId": {
+                    "description": "песня, которая остается",
+                    "type": "integer"
+                },
+                "mergeIds": {
+                    "description": "песни, которые объединяются с ней и переносятся в корзину",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "entities.Playlist": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "description": "заполняется только при получении одного плейлиста",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.PlaylistEntry"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "songs": {
+                    "description": "количество записей в плейлисте",
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.PlaylistEntry": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "position": {
+                    "description": "позиция начиная с 1. При добавлении 0 означает конец плейлиста",
+                    "type": "integer"
+                },
+                "song": {
+                    "$ref": "#/definitions/entities.Song"
+                },
+                "songId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.Song": {
+            "type": "object",
+            "properties": {
+                "albumId": {
+                    "description": "только для чтения, песни добавляются в альбом через /albums/{id}/songs",
+                    "type": "integer"
+                },
+                "artistId": {
+                    "description": "заполняется сервером по group",
+                    "type": "integer"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "lyrics": {
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "type": "string"
+                },
+                "sources": {
+                    "description": "только для чтения: откуда взяты releaseDate, lyrics и link - имя поставщика метаданных или client",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "trackNumber": {
+                    "description": "только для чтения",
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.SongEnrichment": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "enrichedAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "ошибка последней неудачной попытки",
+                    "type": "string"
+                },
+                "nextAttemptAt": {
+                    "description": "когда будет следующая попытка, если статус pending",
+                    "type": "string"
+                },
+                "songId": {
+                    "type": "integer"
+                },
+                "sources": {
+                    "description": "откуда взяты releaseDate, lyrics и link - имя поставщика метаданных или client",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "description": "pending, done или failed",
+                    "type": "string"
+                }
+            }
+        },
+        "entities.SongRevision": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "changeType": {
+                    "description": "create, update, patch, restore, delete, undelete, purge или merge",
+                    "type": "string"
+                },
+                "changedAt": {
+                    "type": "string"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "song": {
+                    "description": "состояние песни после изменения",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.Song"
+                        }
+                    ]
+                },
+                "songId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.SongVerses": {
             "type": "object",
             "properties": {
                 "page": {
@@ -417,6 +3298,82 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "entities.Tag": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "description": "genre или tag",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "entities.TagCount": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "description": "genre или tag",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "songs": {
+                    "type": "integer"
+                }
+            }
+        },
+        "entities.TrashedSong": {
+            "type": "object",
+            "properties": {
+                "albumId": {
+                    "description": "только для чтения, песни добавляются в альбом через /albums/{id}/songs",
+                    "type": "integer"
+                },
+                "artistId": {
+                    "description": "заполняется сервером по group",
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "lyrics": {
+                    "type": "string"
+                },
+                "purgeAt": {
+                    "description": "после этого момента песня будет удалена окончательно",
+                    "type": "string"
+                },
+                "releaseDate": {
+                    "type": "string"
+                },
+                "sources": {
+                    "description": "только для чтения: откуда взяты releaseDate, lyrics и link - имя поставщика метаданных или client",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "trackNumber": {
+                    "description": "только для чтения",
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
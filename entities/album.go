@@ -0,0 +1,15 @@
+package entities
+
+type Album struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	ArtistId    int    `json:"artistId"` // заполняется сервером по artist
+	ReleaseDate string `json:"releaseDate"`
+	CoverLink   string `json:"coverLink"`
+}
+
+type AlbumTrack struct {
+	SongId      int `json:"songId"`
+	TrackNumber int `json:"trackNumber"`
+}
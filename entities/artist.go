@@ -0,0 +1,7 @@
+package entities
+
+type Artist struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,17 @@
+package entities
+
+type BatchReport struct {
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"` // дубликаты
+	Failed  int               `json:"failed"`
+	Items   []BatchItemResult `json:"items"`
+}
+
+type BatchItemResult struct {
+	Index  int    `json:"index"` // позиция песни в запросе, начиная с 0
+	Title  string `json:"title"`
+	Group  string `json:"group"`
+	Status string `json:"status"`       // created, duplicate, invalid, enrichment_failed или failed
+	Id     int    `json:"id,omitempty"` // id созданной песни или уже существующей песни, дубликатом которой оказалась эта
+	Error  string `json:"error,omitempty"`
+}
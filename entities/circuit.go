@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+type CircuitStatus struct {
+	State            string     `json:"state"`            // closed, open или half-open
+	Failures         int        `json:"failures"`         // неудачных запросов подряд в состоянии closed
+	FailureThreshold int        `json:"failureThreshold"` // после стольких неудач подряд автомат размыкается
+	OpenTimeout      float64    `json:"openTimeout"`      // секунд в состоянии open до пробного запроса
+	OpenedAt         *time.Time `json:"openedAt"`
+	RetryAfter       float64    `json:"retryAfter"` // секунд до пробного запроса, если автомат разомкнут
+}
@@ -0,0 +1,27 @@
+package entities
+
+type LyricsDiff struct {
+	SongId int              `json:"songId"`
+	From   string           `json:"from"` // номер ревизии или current
+	To     string           `json:"to"`
+	Hunks  []LyricsDiffHunk `json:"hunks"`
+}
+
+// LyricsDiffHunk - фрагмент изменений в формате unified diff. Номера строк начинаются с 1
+type LyricsDiffHunk struct {
+	FromStart int              `json:"fromStart"`
+	FromLines int              `json:"fromLines"`
+	ToStart   int              `json:"toStart"`
+	ToLines   int              `json:"toLines"`
+	Verse     int              `json:"verse"` // куплет, в котором начинаются изменения
+	Lines     []LyricsDiffLine `json:"lines"`
+}
+
+type LyricsDiffLine struct {
+	Op        string `json:"op"` // context, delete или insert
+	Text      string `json:"text"`
+	FromLine  int    `json:"fromLine,omitempty"`
+	ToLine    int    `json:"toLine,omitempty"`
+	FromVerse int    `json:"fromVerse,omitempty"` // не заполняется для пустой строки между куплетами
+	ToVerse   int    `json:"toVerse,omitempty"`
+}
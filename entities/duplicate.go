@@ -0,0 +1,21 @@
+package entities
+
+type DuplicatePair struct {
+	First      DuplicateSong `json:"first"`
+	Second     DuplicateSong `json:"second"`
+	Similarity float64       `json:"similarity"` // похожесть названий от 0 до 1
+	Exact      bool          `json:"exact"`      // у песен один исполнитель и одинаковое нормализованное название
+}
+
+type DuplicateSong struct {
+	Id           int    `json:"id"`
+	Title        string `json:"title"`
+	Group        string `json:"group"`
+	LyricsLength int    `json:"lyricsLength"`
+	Link         string `json:"link"`
+}
+
+type MergeSongsRequest struct {
+	KeepId   int   `json:"keepId"`   // песня, которая остается
+	MergeIds []int `json:"mergeIds"` // песни, которые объединяются с ней и переносятся в корзину
+}
@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+type SongEnrichment struct {
+	SongId        int               `json:"songId"`
+	Status        string            `json:"status"` // pending, done или failed
+	Attempts      int               `json:"attempts"`
+	Error         string            `json:"error,omitempty"` // ошибка последней неудачной попытки
+	NextAttemptAt *time.Time        `json:"nextAttemptAt"`   // когда будет следующая попытка, если статус pending
+	EnrichedAt    *time.Time        `json:"enrichedAt"`
+	Sources       map[string]string `json:"sources"` // откуда взяты releaseDate, lyrics и link - имя поставщика метаданных или client
+}
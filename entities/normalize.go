@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CleanArtistName убирает пробелы по краям и схлопывает повторяющиеся пробелы. Так имя хранится и отображается
+func CleanArtistName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NormalizeArtistName приводит имя к виду, по которому исполнители сравниваются между собой: "KALEO " и "Kaleo" - один исполнитель
+func NormalizeArtistName(name string) string {
+	return strings.ToLower(CleanArtistName(name))
+}
+
+// NormalizeSongTitle приводит название к виду, по которому песни сравниваются между собой:
+// "Little Talks!" и "little  talks" - одна песня. Должно совпадать с выражением столбца songs.normalized_title из миграции 000012
+func NormalizeSongTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return r
+		}
+		return -1
+	}, title)
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
@@ -0,0 +1,15 @@
+package entities
+
+type Playlist struct {
+	Id      int             `json:"id"`
+	Name    string          `json:"name"`
+	Songs   int             `json:"songs"`             // количество записей в плейлисте
+	Entries []PlaylistEntry `json:"entries,omitempty"` // заполняется только при получении одного плейлиста
+}
+
+type PlaylistEntry struct {
+	Id       int   `json:"id"`
+	Position int   `json:"position"` // позиция начиная с 1. При добавлении 0 означает конец плейлиста
+	SongId   int   `json:"songId"`
+	Song     *Song `json:"song,omitempty"`
+}
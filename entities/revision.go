@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+type SongRevision struct {
+	Revision   int       `json:"revision"`
+	SongId     int       `json:"songId"`
+	ChangeType string    `json:"changeType"` // create, update, patch, restore, delete, undelete, purge или merge
+	Actor      string    `json:"actor"`
+	ChangedAt  time.Time `json:"changedAt"`
+	Song       Song      `json:"song"` // состояние песни после изменения
+}
@@ -1,16 +1,52 @@
 package entities
 
+import "time"
+
 type Song struct {
 	Id          int
-	Title       string `json:"title"`
-	Group       string `json:"group"`
-	ReleaseDate string `json:"releaseDate"`
-	Lyrics      string `json:"lyrics"`
-	Link        string `json:"link"`
+	Title       string            `json:"title"`
+	Group       string            `json:"group"`
+	ArtistId    int               `json:"artistId"` // заполняется сервером по group
+	ReleaseDate string            `json:"releaseDate"`
+	Lyrics      string            `json:"lyrics"`
+	Link        string            `json:"link"`
+	AlbumId     *int              `json:"albumId"`           // только для чтения, песни добавляются в альбом через /albums/{id}/songs
+	TrackNumber *int              `json:"trackNumber"`       // только для чтения
+	Sources     map[string]string `json:"sources,omitempty"` // только для чтения: откуда взяты releaseDate, lyrics и link - имя поставщика метаданных или client
+	UpdatedAt   time.Time         `json:"-"`
+	Version     int               `json:"-"` // отдается в заголовке ETag
 }
 
+// Ключи Song.Sources - поля, которые заполняют поставщики метаданных
+const (
+	FieldReleaseDate = "releaseDate"
+	FieldLyrics      = "lyrics"
+	FieldLink        = "link"
+)
+
 type SongVerses struct {
 	Verses        []string `json:"verses"`
 	Page          int      `json:"page"`
 	VersesPerPage int      `json:"versesPerPage"`
 }
+
+type LibraryPage struct {
+	Items      []Song `json:"items"`
+	NextCursor string `json:"nextCursor"`
+}
+
+type LibraryEnvelope struct {
+	Items        interface{} `json:"items"` // []Song или []LyricsSearchHit в режиме полнотекстового поиска
+	Total        int         `json:"total"`
+	Page         int         `json:"page"`
+	SongsPerPage int         `json:"songsPerPage"`
+	TotalPages   int         `json:"totalPages"`
+	Prev         *string     `json:"prev"`
+	Next         *string     `json:"next"`
+}
+
+type LyricsSearchHit struct {
+	Song
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"` // фрагменты текста с найденными словами, выделенными тегом <b>
+}
@@ -0,0 +1,11 @@
+package entities
+
+type Tag struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // genre или tag
+}
+
+type TagCount struct {
+	Tag
+	Songs int `json:"songs"`
+}
@@ -0,0 +1,9 @@
+package entities
+
+import "time"
+
+type TrashedSong struct {
+	Song
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"` // после этого момента песня будет удалена окончательно
+}
@@ -42,17 +42,76 @@ func main() {
 		}
 	}()
 
+	models.StartTrashPurger()
+	if err := controllers.InitMetadataProviders(); err != nil {
+		logrus.Fatal("Error initializing metadata providers ", err)
+	}
+	controllers.StartEnrichmentWorkers()
+
 	router := mux.NewRouter()
 
-	router.HandleFunc("/songs", controllers.AddSong).Methods(http.MethodPost) // добавление песни
+	router.HandleFunc("/songs", controllers.AddSong).Methods(http.MethodPost)             // добавление песни
+	router.HandleFunc("/songs:batch", controllers.AddSongsBatch).Methods(http.MethodPost) // добавление пакета песен
 
+	router.HandleFunc("/songs/{id:[0-9]+}", controllers.GetSong).Methods(http.MethodGet)       // получение песни
 	router.HandleFunc("/songs/{id:[0-9]+}", controllers.UpdateSong).Methods(http.MethodPut)    // изменение песни
 	router.HandleFunc("/songs/{id:[0-9]+}", controllers.PatchSong).Methods(http.MethodPatch)   // частичное изменение песни
-	router.HandleFunc("/songs/{id:[0-9]+}", controllers.DeleteSong).Methods(http.MethodDelete) // удаление песни
+	router.HandleFunc("/songs/{id:[0-9]+}", controllers.DeleteSong).Methods(http.MethodDelete) // удаление песни в корзину
+
+	router.HandleFunc("/songs/{id:[0-9]+}/restore", controllers.RestoreSong).Methods(http.MethodPost) // восстановление песни из корзины
+	router.HandleFunc("/trash", controllers.GetTrash).Methods(http.MethodGet)                         // получение песен из корзины
+
+	router.HandleFunc("/admin/duplicates", controllers.GetDuplicates).Methods(http.MethodGet)          // поиск вероятных дубликатов песен
+	router.HandleFunc("/admin/duplicates/merge", controllers.MergeDuplicates).Methods(http.MethodPost) // объединение дубликатов
+	router.HandleFunc("/admin/song-info", controllers.GetSongInfoStatus).Methods(http.MethodGet)       // состояние автомата перед сторонним API
+
+	router.HandleFunc("/songs/{id:[0-9]+}/lyrics", controllers.GetSongLyrics).Methods(http.MethodGet)       // получение текста песни с пагинацией по куплетам
+	router.HandleFunc("/songs/{id:[0-9]+}/lyrics/diff", controllers.DiffSongLyrics).Methods(http.MethodGet) // построчное сравнение текста между ревизиями
+
+	router.HandleFunc("/songs/{id:[0-9]+}/enrichment", controllers.GetSongEnrichment).Methods(http.MethodGet) // состояние обогащения песни данными стороннего API
+	router.HandleFunc("/songs/{id:[0-9]+}/enrichment", controllers.EnrichSong).Methods(http.MethodPost)       // повторное обогащение песни
+
+	router.HandleFunc("/songs/{id:[0-9]+}/revisions", controllers.GetSongRevisions).Methods(http.MethodGet)                          // история изменений песни
+	router.HandleFunc("/songs/{id:[0-9]+}/revisions/{rev:[0-9]+}", controllers.GetSongRevision).Methods(http.MethodGet)              // получение ревизии песни
+	router.HandleFunc("/songs/{id:[0-9]+}/revisions/{rev:[0-9]+}/restore", controllers.RestoreSongRevision).Methods(http.MethodPost) // восстановление ревизии
+
+	router.HandleFunc("/songs/{id:[0-9]+}/tags", controllers.GetSongTags).Methods(http.MethodGet)            // получение тегов песни
+	router.HandleFunc("/songs/{id:[0-9]+}/tags", controllers.AttachSongTag).Methods(http.MethodPost)         // добавление тега песне
+	router.HandleFunc("/songs/{id:[0-9]+}/tags/{tag}", controllers.DetachSongTag).Methods(http.MethodDelete) // удаление тега у песни
+
+	router.HandleFunc("/playlists", controllers.GetPlaylists).Methods(http.MethodGet) // получение списка плейлистов
+	router.HandleFunc("/playlists", controllers.AddPlaylist).Methods(http.MethodPost) // создание плейлиста
+
+	router.HandleFunc("/playlists/{id:[0-9]+}", controllers.GetPlaylist).Methods(http.MethodGet)       // получение плейлиста с песнями
+	router.HandleFunc("/playlists/{id:[0-9]+}", controllers.RenamePlaylist).Methods(http.MethodPut)    // переименование плейлиста
+	router.HandleFunc("/playlists/{id:[0-9]+}", controllers.DeletePlaylist).Methods(http.MethodDelete) // удаление плейлиста
+
+	router.HandleFunc("/playlists/{id:[0-9]+}/entries", controllers.AddPlaylistEntry).Methods(http.MethodPost)                       // добавление песни в плейлист
+	router.HandleFunc("/playlists/{id:[0-9]+}/entries/{entryId:[0-9]+}", controllers.MovePlaylistEntry).Methods(http.MethodPut)      // перестановка песни в плейлисте
+	router.HandleFunc("/playlists/{id:[0-9]+}/entries/{entryId:[0-9]+}", controllers.RemovePlaylistEntry).Methods(http.MethodDelete) // удаление песни из плейлиста
+
+	router.HandleFunc("/tags", controllers.GetTagCounts).Methods(http.MethodGet) // облако тегов с количеством песен
+
+	router.HandleFunc("/artists", controllers.GetArtists).Methods(http.MethodGet) // получение списка исполнителей
+	router.HandleFunc("/artists", controllers.AddArtist).Methods(http.MethodPost) // добавление исполнителя
+
+	router.HandleFunc("/artists/{id:[0-9]+}", controllers.GetArtist).Methods(http.MethodGet)       // получение исполнителя
+	router.HandleFunc("/artists/{id:[0-9]+}", controllers.UpdateArtist).Methods(http.MethodPut)    // изменение исполнителя
+	router.HandleFunc("/artists/{id:[0-9]+}", controllers.DeleteArtist).Methods(http.MethodDelete) // удаление исполнителя
+
+	router.HandleFunc("/albums", controllers.GetAlbums).Methods(http.MethodGet) // получение списка альбомов
+	router.HandleFunc("/albums", controllers.AddAlbum).Methods(http.MethodPost) // добавление альбома
+
+	router.HandleFunc("/albums/{id:[0-9]+}", controllers.GetAlbum).Methods(http.MethodGet)       // получение альбома
+	router.HandleFunc("/albums/{id:[0-9]+}", controllers.UpdateAlbum).Methods(http.MethodPut)    // изменение альбома
+	router.HandleFunc("/albums/{id:[0-9]+}", controllers.DeleteAlbum).Methods(http.MethodDelete) // удаление альбома
 
-	router.HandleFunc("/songs/{id:[0-9]+}/lyrics", controllers.GetSongLyrics).Methods(http.MethodGet) // получение текста песни с пагинацией по куплетам
+	router.HandleFunc("/albums/{id:[0-9]+}/songs", controllers.GetAlbumSongs).Methods(http.MethodGet)                       // получение треков альбома
+	router.HandleFunc("/albums/{id:[0-9]+}/songs", controllers.SetAlbumTrack).Methods(http.MethodPost)                      // добавление песни в альбом
+	router.HandleFunc("/albums/{id:[0-9]+}/songs/{songId:[0-9]+}", controllers.RemoveAlbumTrack).Methods(http.MethodDelete) // удаление песни из альбома
 
-	router.HandleFunc("/library", controllers.GetLibrary).Methods(http.MethodGet) // получение данных библиотеки с фильтрацией по всем полям и пагинацией
+	router.HandleFunc("/library", controllers.GetLibrary).Methods(http.MethodGet)           // получение данных библиотеки с фильтрацией по всем полям и пагинацией
+	router.HandleFunc("/library/export", controllers.ExportLibrary).Methods(http.MethodGet) // выгрузка библиотеки в csv, ndjson или json
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler) // swagger UI
 
@@ -0,0 +1 @@
+ALTER TABLE songs DROP COLUMN updated_at;
@@ -0,0 +1 @@
+ALTER TABLE songs ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,4 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_songs_title_trgm ON songs USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_songs_group_name_trgm ON songs USING GIN (group_name gin_trgm_ops);
@@ -0,0 +1,2 @@
+ALTER TABLE songs DROP COLUMN artist_id;
+DROP TABLE artists;
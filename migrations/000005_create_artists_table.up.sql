@@ -0,0 +1,28 @@
+-- Имя исполнителя нормализуется так же, как в entities.NormalizeArtistName: пробелы по краям убираются,
+-- повторяющиеся пробелы схлопываются, регистр не учитывается
+CREATE TABLE artists (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    normalized_name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO artists (name, normalized_name)
+SELECT DISTINCT ON (lower(btrim(regexp_replace(group_name, '\s+', ' ', 'g'))))
+       btrim(regexp_replace(group_name, '\s+', ' ', 'g')),
+       lower(btrim(regexp_replace(group_name, '\s+', ' ', 'g')))
+FROM songs
+ORDER BY lower(btrim(regexp_replace(group_name, '\s+', ' ', 'g'))), id;
+
+ALTER TABLE songs ADD COLUMN artist_id INT REFERENCES artists(id) ON DELETE RESTRICT;
+
+-- group_name остается денормализованной копией имени исполнителя, чтобы фильтры, сортировка и индексы по нему продолжали работать
+UPDATE songs
+SET artist_id = artists.id,
+    group_name = artists.name
+FROM artists
+WHERE artists.normalized_name = lower(btrim(regexp_replace(songs.group_name, '\s+', ' ', 'g')));
+
+ALTER TABLE songs ALTER COLUMN artist_id SET NOT NULL;
+
+CREATE INDEX idx_songs_artist_id ON songs(artist_id);
@@ -0,0 +1,17 @@
+CREATE TABLE albums (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    artist_id INT NOT NULL REFERENCES artists(id) ON DELETE RESTRICT,
+    release_date DATE,
+    cover_link VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_albums_artist_id ON albums(artist_id);
+
+ALTER TABLE songs
+    ADD COLUMN album_id INT REFERENCES albums(id),
+    ADD COLUMN track_number INT CHECK (track_number > 0),
+    ADD CONSTRAINT songs_album_track_check CHECK ((album_id IS NULL) = (track_number IS NULL)),
+    ADD CONSTRAINT songs_album_track_unique UNIQUE (album_id, track_number);
+
+CREATE INDEX idx_songs_album_id ON songs(album_id);
@@ -0,0 +1,19 @@
+CREATE TABLE playlists (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Одна песня может встречаться в плейлисте несколько раз, поэтому у записи свой id.
+-- Уникальность позиции проверяется в конце транзакции, чтобы сдвиг позиций при вставке и перестановке не нарушал ее на промежуточных шагах.
+-- Удаление песни, входящей в плейлист, запрещено на уровне бд: models.DeleteSong сам удаляет записи или отказывает в зависимости от настройки
+CREATE TABLE playlist_entries (
+    id SERIAL PRIMARY KEY,
+    playlist_id INT NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+    song_id INT NOT NULL REFERENCES songs(id) ON DELETE RESTRICT,
+    position INT NOT NULL CHECK (position > 0),
+    CONSTRAINT playlist_entries_position_unique UNIQUE (playlist_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX idx_playlist_entries_song_id ON playlist_entries(song_id);
@@ -0,0 +1,21 @@
+-- Полные снимки песни после каждого изменения. Внешнего ключа на songs нет, чтобы история сохранялась и после удаления песни
+CREATE TABLE song_revisions (
+    id SERIAL PRIMARY KEY,
+    song_id INT NOT NULL,
+    revision INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    group_name VARCHAR(255) NOT NULL,
+    artist_id INT NOT NULL,
+    release_date DATE,
+    lyrics TEXT,
+    link VARCHAR(255),
+    change_type VARCHAR(16) NOT NULL CHECK (change_type IN ('create', 'update', 'patch', 'restore', 'delete')),
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (song_id, revision)
+);
+
+-- Текущее состояние уже существующих песен становится их первой ревизией
+INSERT INTO song_revisions (song_id, revision, title, group_name, artist_id, release_date, lyrics, link, change_type, changed_at)
+SELECT id, 1, title, group_name, artist_id, release_date, lyrics, link, 'create', updated_at
+FROM songs;
@@ -0,0 +1,10 @@
+-- песни из корзины после отката снова становятся видимыми
+UPDATE song_revisions SET change_type = 'restore' WHERE change_type = 'undelete';
+UPDATE song_revisions SET change_type = 'delete' WHERE change_type = 'purge';
+
+ALTER TABLE song_revisions DROP CONSTRAINT song_revisions_change_type_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_change_type_check
+    CHECK (change_type IN ('create', 'update', 'patch', 'restore', 'delete'));
+
+DROP INDEX idx_songs_deleted_at;
+ALTER TABLE songs DROP COLUMN deleted_at;
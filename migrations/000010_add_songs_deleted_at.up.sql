@@ -0,0 +1,8 @@
+-- Мягкое удаление: песня с заполненным deleted_at находится в корзине и не попадает в выдачу
+ALTER TABLE songs ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_songs_deleted_at ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE song_revisions DROP CONSTRAINT song_revisions_change_type_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_change_type_check
+    CHECK (change_type IN ('create', 'update', 'patch', 'restore', 'delete', 'undelete', 'purge'));
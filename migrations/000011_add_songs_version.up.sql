@@ -0,0 +1,2 @@
+-- Версия увеличивается при каждом изменении песни и используется как ETag для оптимистичной блокировки
+ALTER TABLE songs ADD COLUMN version INT NOT NULL DEFAULT 1;
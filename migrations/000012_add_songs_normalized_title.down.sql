@@ -0,0 +1,8 @@
+UPDATE song_revisions SET change_type = 'update' WHERE change_type = 'merge';
+
+ALTER TABLE song_revisions DROP CONSTRAINT song_revisions_change_type_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_change_type_check
+    CHECK (change_type IN ('create', 'update', 'patch', 'restore', 'delete', 'undelete', 'purge'));
+
+DROP INDEX idx_songs_artist_normalized_title;
+ALTER TABLE songs DROP COLUMN normalized_title;
@@ -0,0 +1,12 @@
+-- Название без регистра, знаков препинания и лишних пробелов: "Little Talks!" и "little  talks" - одна песня.
+-- Выражение должно совпадать с models.normalizeTitleSQL
+ALTER TABLE songs ADD COLUMN normalized_title VARCHAR(255) GENERATED ALWAYS AS (
+    lower(btrim(regexp_replace(regexp_replace(title, '[^[:alnum:][:space:]]+', '', 'g'), '[[:space:]]+', ' ', 'g')))
+) STORED;
+
+-- Индекс не уникальный: в уже загруженной библиотеке могут быть дубликаты, они объединяются через /admin/duplicates/merge
+CREATE INDEX idx_songs_artist_normalized_title ON songs (artist_id, normalized_title) WHERE deleted_at IS NULL;
+
+ALTER TABLE song_revisions DROP CONSTRAINT song_revisions_change_type_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_change_type_check
+    CHECK (change_type IN ('create', 'update', 'patch', 'restore', 'delete', 'undelete', 'purge', 'merge'));
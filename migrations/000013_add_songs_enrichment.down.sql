@@ -0,0 +1,8 @@
+DROP INDEX idx_songs_enrichment_pending;
+
+ALTER TABLE songs
+    DROP COLUMN enrichment_status,
+    DROP COLUMN enrichment_attempts,
+    DROP COLUMN enrichment_error,
+    DROP COLUMN enrichment_next_at,
+    DROP COLUMN enriched_at;
@@ -0,0 +1,10 @@
+-- Состояние обогащения песни данными стороннего API. В асинхронном режиме песня сохраняется сразу со статусом pending,
+-- а дата выхода, текст и ссылка заполняются фоновыми обработчиками. Песни, добавленные раньше, уже обогащены
+ALTER TABLE songs
+    ADD COLUMN enrichment_status VARCHAR(16) NOT NULL DEFAULT 'done' CHECK (enrichment_status IN ('pending', 'done', 'failed')),
+    ADD COLUMN enrichment_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN enrichment_error TEXT,
+    ADD COLUMN enrichment_next_at TIMESTAMPTZ, -- не раньше этого момента песню возьмет обработчик
+    ADD COLUMN enriched_at TIMESTAMPTZ;
+
+CREATE INDEX idx_songs_enrichment_pending ON songs (enrichment_next_at) WHERE enrichment_status = 'pending';
@@ -0,0 +1,3 @@
+-- Происхождение полей песни: поставщик метаданных, из которого взято поле, или client, если его передал клиент.
+-- Ключи - releaseDate, lyrics и link; у пустого поля ключа нет. Для песен, добавленных раньше, происхождение неизвестно
+ALTER TABLE songs ADD COLUMN metadata_sources JSONB NOT NULL DEFAULT '{}';
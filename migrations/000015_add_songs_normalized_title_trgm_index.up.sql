@@ -0,0 +1,2 @@
+-- Поиск дубликатов выбирает кандидатов оператором % по normalized_title, без индекса это перебор всех пар песен
+CREATE INDEX idx_songs_normalized_title_trgm ON songs USING GIN (normalized_title gin_trgm_ops) WHERE deleted_at IS NULL;
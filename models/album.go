@@ -0,0 +1,210 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoAlbumFound     = errors.New("no album found with provided id")
+	ErrTrackNumberTaken = errors.New("track number is already taken in the album")
+	ErrNoTrackFound     = errors.New("song is not in the album")
+)
+
+func scanAlbum(row interface{ Scan(...interface{}) error }, album *entities.Album) error {
+	var releaseDate sql.NullTime
+	err := row.Scan(&album.Id, &album.Title, &album.ArtistId, &album.Artist, &releaseDate, &album.CoverLink)
+	if err != nil {
+		return err
+	}
+	album.ReleaseDate = ""
+	if releaseDate.Valid {
+		album.ReleaseDate = releaseDate.Time.Format("02.01.2006")
+	}
+	return nil
+}
+
+func GetAlbums(artistId, limit, offset int) ([]entities.Album, error) {
+	query := "SELECT albums.id, albums.title, albums.artist_id, artists.name, albums.release_date, albums.cover_link FROM albums JOIN artists ON artists.id = albums.artist_id"
+	args := []interface{}{}
+	if artistId != 0 {
+		query += " WHERE albums.artist_id = $1"
+		args = append(args, artistId)
+	}
+	query += fmt.Sprintf(" ORDER BY albums.release_date NULLS LAST, albums.id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := Db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting albums: %w", err)
+	}
+	defer rows.Close()
+
+	albums := []entities.Album{}
+	for rows.Next() {
+		var album entities.Album
+		if err := scanAlbum(rows, &album); err != nil {
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+func GetAlbum(id int) (*entities.Album, error) {
+	var album entities.Album
+	row := Db.QueryRow("SELECT albums.id, albums.title, albums.artist_id, artists.name, albums.release_date, albums.cover_link FROM albums JOIN artists ON artists.id = albums.artist_id WHERE albums.id = $1", id)
+	err := scanAlbum(row, &album)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoAlbumFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting album: %w", err)
+	}
+	return &album, nil
+}
+
+// AddAlbum добавляет альбом. Исполнитель ищется по имени так же, как для песен, и создается, если его еще нет.
+// Дата релиза передается в формате YYYY-MM-DD, пустая строка означает отсутствие даты
+func AddAlbum(album *entities.Album) error {
+	row := Db.QueryRow("WITH "+upsertArtistCTE+" INSERT INTO albums (title, artist_id, release_date, cover_link) SELECT $3::varchar, artist.id, NULLIF($4::varchar, '')::date, $5::varchar FROM artist RETURNING id, artist_id",
+		entities.CleanArtistName(album.Artist), entities.NormalizeArtistName(album.Artist), album.Title, album.ReleaseDate, album.CoverLink)
+	if err := row.Scan(&album.Id, &album.ArtistId); err != nil {
+		return fmt.Errorf("error while adding album: %w", err)
+	}
+
+	added, err := GetAlbum(album.Id)
+	if err != nil {
+		return err
+	}
+	*album = *added
+	return nil
+}
+
+// UpdateAlbum изменяет альбом. Изменение выполняется в транзакции, чтобы для несуществующего альбома не оставался созданный исполнитель
+func UpdateAlbum(id int, album *entities.Album) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("WITH "+upsertArtistCTE+" UPDATE albums SET title = $3, artist_id = artist.id, release_date = NULLIF($4::varchar, '')::date, cover_link = $5 FROM artist WHERE albums.id = $6",
+		entities.CleanArtistName(album.Artist), entities.NormalizeArtistName(album.Artist), album.Title, album.ReleaseDate, album.CoverLink, id)
+	if err != nil {
+		return fmt.Errorf("error while updating album: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoAlbumFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteAlbum удаляет альбом. Сами песни остаются в библиотеке, но перестают к нему относиться; у каждой из них записывается ревизия от имени actor
+func DeleteAlbum(id int, actor string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = updateSongsWithRevisions(tx, actor, "UPDATE songs SET album_id = NULL, track_number = NULL, version = version + 1, updated_at = now() WHERE album_id = $1 RETURNING id", id)
+	if err != nil {
+		return fmt.Errorf("error while detaching album songs: %w", err)
+	}
+
+	result, err := tx.Exec("DELETE FROM albums WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error while deleting album: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoAlbumFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// GetAlbumSongs возвращает песни альбома в порядке треков
+func GetAlbumSongs(id int) ([]entities.Song, error) {
+	if _, err := GetAlbum(id); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number FROM songs WHERE album_id = $1 AND deleted_at IS NULL ORDER BY track_number`
+	songs, err := queryLibrary(Db, query, []interface{}{id})
+	if err != nil {
+		return nil, fmt.Errorf("error while getting album songs: %w", err)
+	}
+	return songs, nil
+}
+
+// SetAlbumTrack помещает песню в альбом под указанным номером. Если песня уже была в другом альбоме, она переносится.
+// Изменение записывается в историю ревизий песни от имени actor
+func SetAlbumTrack(albumId int, track *entities.AlbumTrack, actor string) error {
+	if _, err := GetAlbum(albumId); err != nil {
+		return err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := updateSongsWithRevisions(tx, actor, "UPDATE songs SET album_id = $1, track_number = $2, version = version + 1, updated_at = now() WHERE id = $3 AND deleted_at IS NULL RETURNING id",
+		albumId, track.TrackNumber, track.SongId)
+	if err != nil && isPqError(err, pqUniqueViolation) {
+		return ErrTrackNumberTaken
+	} else if err != nil {
+		return fmt.Errorf("error while setting album track: %w", err)
+	}
+	if updated == 0 {
+		return ErrNoSongFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+func RemoveAlbumTrack(albumId, songId int, actor string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated, err := updateSongsWithRevisions(tx, actor, "UPDATE songs SET album_id = NULL, track_number = NULL, version = version + 1, updated_at = now() WHERE id = $1 AND album_id = $2 RETURNING id", songId, albumId)
+	if err != nil {
+		return fmt.Errorf("error while removing album track: %w", err)
+	}
+	if updated == 0 {
+		return ErrNoTrackFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
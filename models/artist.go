@@ -0,0 +1,201 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNoArtistFound   = errors.New("no artist found with provided id")
+	ErrArtistExists    = errors.New("artist with such name already exists")
+	ErrArtistHasSongs  = errors.New("artist has songs")
+	ErrArtistHasAlbums = errors.New("artist has albums")
+)
+
+// имена внешних ключей на artists, которые Postgres назначил по умолчанию
+const (
+	songsArtistConstraint  = "songs_artist_id_fkey"
+	albumsArtistConstraint = "albums_artist_id_fkey"
+)
+
+// ArtistTrashedSongsError возвращается, если у исполнителя остались только песни в корзине. Совместима с ErrArtistHasSongs
+type ArtistTrashedSongsError struct {
+	SongIds []int
+}
+
+func (e *ArtistTrashedSongsError) Error() string {
+	return fmt.Sprintf("%v in the trash: %v", ErrArtistHasSongs, e.SongIds)
+}
+
+func (e *ArtistTrashedSongsError) Unwrap() error {
+	return ErrArtistHasSongs
+}
+
+// Коды ошибок postgres, которые обрабатываются отдельно
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+func isPqError(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
+}
+
+// pqConstraint возвращает имя ограничения, нарушение которого вызвало ошибку Postgres
+func pqConstraint(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+// upsertArtistCTE находит исполнителя по нормализованному имени или создает нового. Ожидает имя в $1 и нормализованное имя в $2.
+// DO UPDATE вместо DO NOTHING нужен, чтобы RETURNING возвращал строку и для уже существующего исполнителя
+const upsertArtistCTE = `artist AS (
+	INSERT INTO artists (name, normalized_name) SELECT $1::varchar, $2::varchar WHERE $2::varchar <> ''
+	ON CONFLICT (normalized_name) DO UPDATE SET normalized_name = EXCLUDED.normalized_name
+	RETURNING id, name
+)`
+
+func GetArtists(name string, limit, offset int) ([]entities.Artist, error) {
+	query := "SELECT id, name, description FROM artists"
+	args := []interface{}{}
+	if name != "" {
+		query += " WHERE normalized_name LIKE $1"
+		args = append(args, entities.NormalizeArtistName(name)+"%")
+	}
+	query += fmt.Sprintf(" ORDER BY name, id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := Db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting artists: %w", err)
+	}
+	defer rows.Close()
+
+	artists := []entities.Artist{}
+	for rows.Next() {
+		var artist entities.Artist
+		if err := rows.Scan(&artist.Id, &artist.Name, &artist.Description); err != nil {
+			return nil, err
+		}
+		artists = append(artists, artist)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func GetArtist(id int) (*entities.Artist, error) {
+	var artist entities.Artist
+	err := Db.QueryRow("SELECT id, name, description FROM artists WHERE id = $1", id).Scan(&artist.Id, &artist.Name, &artist.Description)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoArtistFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting artist: %w", err)
+	}
+	return &artist, nil
+}
+
+func AddArtist(artist *entities.Artist) error {
+	artist.Name = entities.CleanArtistName(artist.Name)
+	err := Db.QueryRow("INSERT INTO artists (name, normalized_name, description) VALUES ($1, $2, $3) RETURNING id",
+		artist.Name, entities.NormalizeArtistName(artist.Name), artist.Description).Scan(&artist.Id)
+	if err != nil && isPqError(err, pqUniqueViolation) {
+		return ErrArtistExists
+	} else if err != nil {
+		return fmt.Errorf("error while adding artist: %w", err)
+	}
+	return nil
+}
+
+// UpdateArtist изменяет исполнителя и обновляет имя группы у всех его песен. У каждой измененной песни записывается ревизия от имени actor
+func UpdateArtist(id int, artist *entities.Artist, actor string) error {
+	artist.Name = entities.CleanArtistName(artist.Name)
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE artists SET name = $1, normalized_name = $2, description = $3 WHERE id = $4",
+		artist.Name, entities.NormalizeArtistName(artist.Name), artist.Description, id)
+	if err != nil && isPqError(err, pqUniqueViolation) {
+		return ErrArtistExists
+	} else if err != nil {
+		return fmt.Errorf("error while updating artist: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoArtistFound
+	}
+
+	_, err = updateSongsWithRevisions(tx, actor, "UPDATE songs SET group_name = $1, version = version + 1, updated_at = now() WHERE artist_id = $2 AND group_name <> $1 RETURNING id", artist.Name, id)
+	if err != nil {
+		return fmt.Errorf("error while updating artist songs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	artist.Id = id
+	return nil
+}
+
+// DeleteArtist удаляет исполнителя. Исполнителя, у которого есть песни, удалить нельзя. Если остались только песни в корзине,
+// возвращается *ArtistTrashedSongsError с их id: клиент их не видит, но они еще могут быть восстановлены
+func DeleteArtist(id int) error {
+	rows, err := Db.Query("SELECT id, deleted_at IS NOT NULL FROM songs WHERE artist_id = $1 ORDER BY id", id)
+	if err != nil {
+		return fmt.Errorf("error while getting artist songs: %w", err)
+	}
+	defer rows.Close()
+	trashed := []int{}
+	for rows.Next() {
+		var songId int
+		var deleted bool
+		if err := rows.Scan(&songId, &deleted); err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrArtistHasSongs
+		}
+		trashed = append(trashed, songId)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(trashed) > 0 {
+		return &ArtistTrashedSongsError{SongIds: trashed}
+	}
+
+	// у исполнителя могут быть альбомы, а песню могли добавить после проверки, тогда удаление не пройдет по внешнему ключу
+	result, err := Db.Exec("DELETE FROM artists WHERE id = $1", id)
+	if err != nil && isPqError(err, pqForeignKeyViolation) && pqConstraint(err) == albumsArtistConstraint {
+		return ErrArtistHasAlbums
+	} else if err != nil && isPqError(err, pqForeignKeyViolation) && pqConstraint(err) == songsArtistConstraint {
+		return ErrArtistHasSongs
+	} else if err != nil {
+		return fmt.Errorf("error while deleting artist: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoArtistFound
+	}
+	return nil
+}
@@ -55,7 +55,7 @@ func applyMigrations() error {
 	}
 
 	err = migrations.Up()
-	if err != nil {
+	if err != nil && err != migrate.ErrNoChange {
 		return err
 	}
 	return nil
@@ -100,6 +100,11 @@ func OpenDb() error {
 		if err != nil {
 			return err
 		}
+		logrus.Debug("Db exists. Applying new migrations if any")
+		err = applyMigrations() // бд могла быть создана на предыдущей версии схемы
+		if err != nil {
+			return err
+		}
 	}
 	logrus.Debug("Connection to db established")
 	return nil
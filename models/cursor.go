@@ -0,0 +1,152 @@
+/*	Курсор для keyset-пагинации библиотеки.
+	Курсор хранит значения полей сортировки и id последней выданной песни, поэтому следующая страница
+	начинается строго после нее и не дает дублей или пропусков, если между запросами песни добавляются или удаляются.
+	Для клиента курсор непрозрачен: это base64 от JSON.
+*/
+
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type libraryCursor struct {
+	Sort   string    `json:"s"`
+	Values []*string `json:"v"` // значения полей сортировки кроме id, nil соответствует NULL
+	Id     int       `json:"id"`
+}
+
+// sortSignature описывает сортировку строкой, чтобы курсор нельзя было применить к выдаче с другим порядком
+func sortSignature(fields []SortField) string {
+	parts := []string{}
+	for _, field := range orderFields(fields) {
+		if field.Desc {
+			parts = append(parts, "-"+field.Column)
+		} else {
+			parts = append(parts, field.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func songSortValue(song *entities.Song, column string) (*string, error) {
+	var value string
+	switch column {
+	case "title":
+		value = song.Title
+	case "group_name":
+		value = song.Group
+	case "release_date":
+		if song.ReleaseDate == "" {
+			return nil, nil
+		}
+		releaseDate, err := ParseReleaseDate(song.ReleaseDate)
+		if err != nil {
+			return nil, err
+		}
+		value = releaseDate
+	default:
+		return nil, fmt.Errorf("unsupported sort column %q", column)
+	}
+	return &value, nil
+}
+
+func encodeLibraryCursor(sort []SortField, last *entities.Song) (string, error) {
+	cursor := libraryCursor{Sort: sortSignature(sort), Values: []*string{}, Id: last.Id}
+	for _, field := range orderFields(sort) {
+		if field.Column == "id" {
+			continue
+		}
+		value, err := songSortValue(last, field.Column)
+		if err != nil {
+			return "", fmt.Errorf("error while encoding cursor: %w", err)
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	data, err := json.Marshal(&cursor)
+	if err != nil {
+		return "", fmt.Errorf("error while encoding cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeLibraryCursor(encoded string, sort []SortField) (*libraryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor libraryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sortSignature(sort) {
+		return nil, fmt.Errorf("%w: cursor was issued for another sort order", ErrInvalidCursor)
+	}
+	if len(cursor.Values) != len(orderFields(sort))-1 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// buildKeysetCondition строит условие "строка идет после курсора" для произвольной сортировки:
+// (a > va) OR (a = va AND b > vb) OR ... . Учитывается, что Postgres ставит NULL последними при ASC и первыми при DESC
+func buildKeysetCondition(sort []SortField, cursor *libraryCursor, args []interface{}) (string, []interface{}) {
+	equal := []string{}
+	alternatives := []string{}
+	valueIndex := 0
+
+	for _, field := range orderFields(sort) {
+		column := field.Column
+		var value *string
+		if column == "id" {
+			id := fmt.Sprint(cursor.Id)
+			value = &id
+		} else {
+			value = cursor.Values[valueIndex]
+			valueIndex++
+		}
+
+		cast := ""
+		if column == "release_date" {
+			cast = "::date"
+		} else if column == "id" {
+			cast = "::int"
+		}
+
+		var after, same string
+		if value == nil {
+			same = column + " IS NULL"
+			if field.Desc {
+				after = column + " IS NOT NULL"
+			} // при ASC после NULL ничего нет
+		} else {
+			args = append(args, *value)
+			placeholder := fmt.Sprintf("$%d%s", len(args), cast)
+			same = column + " = " + placeholder
+			if field.Desc {
+				after = column + " < " + placeholder
+			} else {
+				after = fmt.Sprintf("(%s > %s OR %s IS NULL)", column, placeholder, column)
+			}
+		}
+
+		if after != "" {
+			alternatives = append(alternatives, "("+strings.Join(append(append([]string{}, equal...), after), " AND ")+")")
+		}
+		equal = append(equal, same)
+	}
+
+	if len(alternatives) == 0 {
+		return "FALSE", args
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
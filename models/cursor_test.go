@@ -0,0 +1,94 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestLibraryCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   []SortField
+		song   entities.Song
+		values []*string
+	}{
+		{
+			name:   "default sort",
+			sort:   []SortField{},
+			song:   entities.Song{Id: 42, Title: "Hysteria"},
+			values: []*string{},
+		},
+		{
+			name:   "sort by release date and title",
+			sort:   []SortField{{Column: "release_date", Desc: true}, {Column: "title"}},
+			song:   entities.Song{Id: 42, Title: "Hysteria", ReleaseDate: "01.12.2003"},
+			values: []*string{stringPointer("2003-12-01"), stringPointer("Hysteria")},
+		},
+		{
+			name:   "empty release date",
+			sort:   []SortField{{Column: "release_date"}},
+			song:   entities.Song{Id: 42, Title: "Hysteria"},
+			values: []*string{nil},
+		},
+		{
+			name:   "explicit id",
+			sort:   []SortField{{Column: "id", Desc: true}, {Column: "group_name"}},
+			song:   entities.Song{Id: 42, Group: "Muse"},
+			values: []*string{stringPointer("Muse")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := encodeLibraryCursor(test.sort, &test.song)
+			if err != nil {
+				t.Fatalf("encodeLibraryCursor() error = %v", err)
+			}
+			cursor, err := decodeLibraryCursor(encoded, test.sort)
+			if err != nil {
+				t.Fatalf("decodeLibraryCursor() error = %v", err)
+			}
+			if cursor.Id != test.song.Id {
+				t.Errorf("cursor id = %d, want %d", cursor.Id, test.song.Id)
+			}
+			if !reflect.DeepEqual(cursor.Values, test.values) {
+				t.Errorf("cursor values = %v, want %v", cursor.Values, test.values)
+			}
+		})
+	}
+}
+
+func TestDecodeLibraryCursorErrors(t *testing.T) {
+	song := entities.Song{Id: 42, Title: "Hysteria", ReleaseDate: "01.12.2003"}
+	issued, err := encodeLibraryCursor([]SortField{{Column: "title"}}, &song)
+	if err != nil {
+		t.Fatalf("encodeLibraryCursor() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		sort    []SortField
+	}{
+		{name: "another sort field", encoded: issued, sort: []SortField{{Column: "release_date"}}},
+		{name: "another sort direction", encoded: issued, sort: []SortField{{Column: "title", Desc: true}}},
+		{name: "default sort", encoded: issued, sort: []SortField{}},
+		{name: "not base64", encoded: "not a cursor!", sort: []SortField{{Column: "title"}}},
+		{name: "not json", encoded: "bm90IGpzb24", sort: []SortField{{Column: "title"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeLibraryCursor(test.encoded, test.sort)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeLibraryCursor() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
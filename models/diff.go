@@ -0,0 +1,309 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CurrentLyrics обозначает текущий текст песни вместо номера ревизии
+const CurrentLyrics = 0
+
+// diffContextLines - количество неизмененных строк вокруг изменений в каждом фрагменте diff
+const diffContextLines = 3
+
+const (
+	DiffContext = "context"
+	DiffDelete  = "delete"
+	DiffInsert  = "insert"
+)
+
+type lyricsLine struct {
+	Text  string
+	Verse int // 0 для пустой строки между куплетами
+}
+
+// GetSongLyricsAt возвращает текст песни в указанной ревизии или текущий текст для CurrentLyrics
+func GetSongLyricsAt(songId, revision int) (string, error) {
+	if revision != CurrentLyrics {
+		songRevision, err := GetSongRevision(songId, revision)
+		if err != nil {
+			return "", err
+		}
+		return songRevision.Song.Lyrics, nil
+	}
+
+	var lyrics sql.NullString
+	err := Db.QueryRow("SELECT lyrics FROM songs WHERE id = $1 AND deleted_at IS NULL", songId).Scan(&lyrics)
+	if err != nil && err == sql.ErrNoRows {
+		return "", ErrNoSongFound
+	} else if err != nil {
+		return "", fmt.Errorf("error while getting song lyrics: %w", err)
+	}
+	return lyrics.String, nil
+}
+
+// DiffSongLyrics построчно сравнивает тексты песни в двух ревизиях. Вместо номера ревизии можно передать CurrentLyrics
+func DiffSongLyrics(songId, from, to int) (*entities.LyricsDiff, error) {
+	fromLyrics, err := GetSongLyricsAt(songId, from)
+	if err != nil {
+		return nil, err
+	}
+	toLyrics, err := GetSongLyricsAt(songId, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := diffLyricsLines(splitLyricsLines(fromLyrics), splitLyricsLines(toLyrics))
+	return &entities.LyricsDiff{
+		SongId: songId,
+		From:   lyricsRevisionLabel(from),
+		To:     lyricsRevisionLabel(to),
+		Hunks:  buildDiffHunks(ops),
+	}, nil
+}
+
+func lyricsRevisionLabel(revision int) string {
+	if revision == CurrentLyrics {
+		return "current"
+	}
+	return strconv.Itoa(revision)
+}
+
+// splitLyricsLines делит текст на куплеты так же, как GetSongLyrics, а куплеты на строки.
+// Между куплетами остается пустая строка, поэтому склейка строк дает исходный текст
+func splitLyricsLines(lyrics string) []lyricsLine {
+	lines := []lyricsLine{}
+	if lyrics == "" {
+		return lines
+	}
+
+	for i, verse := range strings.Split(lyrics, lyricsVerseSplitter()) {
+		if i > 0 {
+			lines = append(lines, lyricsLine{})
+		}
+		for _, line := range strings.Split(verse, lyricsNewline()) {
+			lines = append(lines, lyricsLine{Text: line, Verse: i + 1})
+		}
+	}
+	return lines
+}
+
+// diffLyricsLines строит минимальную последовательность правок через наибольшую общую подпоследовательность строк.
+// Используется алгоритм Хиршберга, поэтому память линейна по длине текстов, а не пропорциональна их произведению
+func diffLyricsLines(from, to []lyricsLine) []entities.LyricsDiffLine {
+	ops := []entities.LyricsDiffLine{}
+	diffLyricsRange(from, to, 0, len(from), 0, len(to), &ops)
+
+	// внутри каждого измененного участка удаленные строки идут перед добавленными, как в unified diff
+	for start := 0; start < len(ops); {
+		if ops[start].Op == DiffContext {
+			start++
+			continue
+		}
+		end := start
+		for end < len(ops) && ops[end].Op != DiffContext {
+			end++
+		}
+		sort.SliceStable(ops[start:end], func(a, b int) bool {
+			return ops[start+a].Op == DiffDelete && ops[start+b].Op == DiffInsert
+		})
+		start = end
+	}
+	return ops
+}
+
+// diffLyricsRange добавляет в ops правки, превращающие from[fromStart:fromEnd] в to[toStart:toEnd]
+func diffLyricsRange(from, to []lyricsLine, fromStart, fromEnd, toStart, toEnd int, ops *[]entities.LyricsDiffLine) {
+	keep := func(i, j int) {
+		*ops = append(*ops, entities.LyricsDiffLine{Op: DiffContext, Text: from[i].Text,
+			FromLine: i + 1, ToLine: j + 1, FromVerse: from[i].Verse, ToVerse: to[j].Verse})
+	}
+	remove := func(i int) {
+		*ops = append(*ops, entities.LyricsDiffLine{Op: DiffDelete, Text: from[i].Text, FromLine: i + 1, FromVerse: from[i].Verse})
+	}
+	insert := func(j int) {
+		*ops = append(*ops, entities.LyricsDiffLine{Op: DiffInsert, Text: to[j].Text, ToLine: j + 1, ToVerse: to[j].Verse})
+	}
+
+	for fromStart < fromEnd && toStart < toEnd && from[fromStart].Text == to[toStart].Text {
+		keep(fromStart, toStart)
+		fromStart++
+		toStart++
+	}
+	suffix := 0
+	for fromStart < fromEnd-suffix && toStart < toEnd-suffix && from[fromEnd-suffix-1].Text == to[toEnd-suffix-1].Text {
+		suffix++
+	}
+	fromEnd -= suffix
+	toEnd -= suffix
+
+	switch {
+	case fromStart == fromEnd:
+		for j := toStart; j < toEnd; j++ {
+			insert(j)
+		}
+	case toStart == toEnd:
+		for i := fromStart; i < fromEnd; i++ {
+			remove(i)
+		}
+	case fromEnd-fromStart == 1:
+		match := -1
+		for j := toStart; j < toEnd && match < 0; j++ {
+			if from[fromStart].Text == to[j].Text {
+				match = j
+			}
+		}
+		if match < 0 {
+			remove(fromStart)
+			match = toEnd
+		}
+		for j := toStart; j < toEnd; j++ {
+			if j == match {
+				keep(fromStart, j)
+			} else {
+				insert(j)
+			}
+		}
+	default:
+		// from делится пополам, а to - в точке, через которую проходит наибольшая общая подпоследовательность
+		middle := (fromStart + fromEnd) / 2
+		forward := lcsLengths(from[fromStart:middle], to[toStart:toEnd], false)
+		backward := lcsLengths(from[middle:fromEnd], to[toStart:toEnd], true)
+		split, best := 0, -1
+		for k := 0; k <= toEnd-toStart; k++ {
+			if length := forward[k] + backward[toEnd-toStart-k]; length > best {
+				split, best = k, length
+			}
+		}
+		diffLyricsRange(from, to, fromStart, middle, toStart, toStart+split, ops)
+		diffLyricsRange(from, to, middle, fromEnd, toStart+split, toEnd, ops)
+	}
+
+	for k := suffix; k > 0; k-- {
+		keep(fromEnd+suffix-k, toEnd+suffix-k)
+	}
+}
+
+// lcsLengths возвращает длины наибольших общих подпоследовательностей from и каждого префикса to длиной j,
+// а при reverse - суффиксов from и to длиной j. Хранятся только две строки таблицы
+func lcsLengths(from, to []lyricsLine, reverse bool) []int {
+	prev := make([]int, len(to)+1)
+	cur := make([]int, len(to)+1)
+	for i := range from {
+		a := from[i]
+		if reverse {
+			a = from[len(from)-1-i]
+		}
+		for j := 1; j <= len(to); j++ {
+			b := to[j-1]
+			if reverse {
+				b = to[len(to)-j]
+			}
+			if a.Text == b.Text {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// buildDiffHunks группирует правки во фрагменты с diffContextLines строками контекста.
+// Изменения, между которыми не больше 2*diffContextLines строк, попадают в один фрагмент
+func buildDiffHunks(ops []entities.LyricsDiffLine) []entities.LyricsDiffHunk {
+	hunks := []entities.LyricsDiffHunk{}
+	for start := 0; start < len(ops); {
+		first := start
+		for first < len(ops) && ops[first].Op == DiffContext {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		begin := max(0, first-diffContextLines)
+		end := first + 1
+		for k := first + 1; k < len(ops) && k <= end+2*diffContextLines; k++ {
+			if ops[k].Op != DiffContext {
+				end = k + 1
+			}
+		}
+		end = min(len(ops), end+diffContextLines)
+
+		hunks = append(hunks, newDiffHunk(ops, begin, end, first))
+		start = end
+	}
+	return hunks
+}
+
+func newDiffHunk(ops []entities.LyricsDiffLine, begin, end, firstChange int) entities.LyricsDiffHunk {
+	hunk := entities.LyricsDiffHunk{Lines: ops[begin:end]}
+	for _, op := range ops[:begin] {
+		if op.Op != DiffInsert {
+			hunk.FromStart++
+		}
+		if op.Op != DiffDelete {
+			hunk.ToStart++
+		}
+	}
+	for _, op := range hunk.Lines {
+		if op.Op != DiffInsert {
+			hunk.FromLines++
+		}
+		if op.Op != DiffDelete {
+			hunk.ToLines++
+		}
+	}
+	// как и в unified diff, пустой диапазон указывается номером строки перед ним
+	if hunk.FromLines > 0 {
+		hunk.FromStart++
+	}
+	if hunk.ToLines > 0 {
+		hunk.ToStart++
+	}
+
+	// пустая строка между куплетами не относится ни к одному из них, поэтому берется первая измененная строка куплета
+	for _, op := range ops[firstChange:end] {
+		if op.Op == DiffContext {
+			continue
+		}
+		if hunk.Verse = op.ToVerse; hunk.Verse == 0 {
+			hunk.Verse = op.FromVerse
+		}
+		if hunk.Verse != 0 {
+			break
+		}
+	}
+	return hunk
+}
+
+// FormatUnifiedLyricsDiff выводит diff в текстовом формате unified diff. Номер куплета указывается в заголовке фрагмента
+func FormatUnifiedLyricsDiff(diff *entities.LyricsDiff) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- song %d revision %s\n", diff.SongId, diff.From)
+	fmt.Fprintf(&b, "+++ song %d revision %s\n", diff.SongId, diff.To)
+	for _, hunk := range diff.Hunks {
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@", hunk.FromStart, hunk.FromLines, hunk.ToStart, hunk.ToLines)
+		if hunk.Verse != 0 {
+			fmt.Fprintf(&b, " verse %d", hunk.Verse)
+		}
+		b.WriteString("\n")
+
+		for _, line := range hunk.Lines {
+			prefix := " "
+			if line.Op == DiffDelete {
+				prefix = "-"
+			} else if line.Op == DiffInsert {
+				prefix = "+"
+			}
+			b.WriteString(prefix + line.Text + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hunkSummary - заголовок фрагмента и его строки с префиксами unified diff, чтобы ожидания в таблице были короткими
+type hunkSummary struct {
+	FromStart, FromLines, ToStart, ToLines, Verse int
+	Lines                                         []string
+}
+
+func summarizeHunks(hunks []entities.LyricsDiffHunk) []hunkSummary {
+	summaries := []hunkSummary{}
+	for _, hunk := range hunks {
+		summary := hunkSummary{hunk.FromStart, hunk.FromLines, hunk.ToStart, hunk.ToLines, hunk.Verse, []string{}}
+		for _, line := range hunk.Lines {
+			prefix := " "
+			if line.Op == DiffDelete {
+				prefix = "-"
+			} else if line.Op == DiffInsert {
+				prefix = "+"
+			}
+			summary.Lines = append(summary.Lines, prefix+line.Text)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func numberedLines(from, to int) []string {
+	lines := []string{}
+	for i := from; i <= to; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	return lines
+}
+
+func TestDiffHunks(t *testing.T) {
+	t.Setenv("SERVER_OS", "linux")
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []hunkSummary
+	}{
+		{
+			name: "same lyrics",
+			from: "a\nb\n\nc",
+			to:   "a\nb\n\nc",
+			want: []hunkSummary{},
+		},
+		{
+			name: "replaced line",
+			from: "a\nb\nc",
+			to:   "a\nx\nc",
+			want: []hunkSummary{{1, 3, 1, 3, 1, []string{" a", "-b", "+x", " c"}}},
+		},
+		{
+			name: "lyrics added to empty song",
+			from: "",
+			to:   "a\nb",
+			want: []hunkSummary{{0, 0, 1, 2, 1, []string{"+a", "+b"}}},
+		},
+		{
+			name: "all lyrics removed",
+			from: "a\nb",
+			to:   "",
+			want: []hunkSummary{{1, 2, 0, 0, 1, []string{"-a", "-b"}}},
+		},
+		{
+			name: "change in second verse",
+			from: "a\nb\n\nc\nd",
+			to:   "a\nb\n\nc\nz",
+			want: []hunkSummary{{2, 4, 2, 4, 2, []string{" b", " ", " c", "-d", "+z"}}},
+		},
+		{
+			name: "distant changes split into hunks",
+			from: strings.Join(numberedLines(1, 10), "\n"),
+			to:   "x\n" + strings.Join(numberedLines(2, 9), "\n") + "\ny",
+			want: []hunkSummary{
+				{1, 4, 1, 4, 1, []string{"-1", "+x", " 2", " 3", " 4"}},
+				{7, 4, 7, 4, 1, []string{" 7", " 8", " 9", "-10", "+y"}},
+			},
+		},
+		{
+			name: "close changes share a hunk",
+			from: "1\n2\n3\n4\n5",
+			to:   "x\n2\n3\n4\ny",
+			want: []hunkSummary{{1, 5, 1, 5, 1, []string{"-1", "+x", " 2", " 3", " 4", "-5", "+y"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := diffLyricsLines(splitLyricsLines(test.from), splitLyricsLines(test.to))
+			got := summarizeHunks(buildDiffHunks(ops))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("hunks = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatUnifiedLyricsDiff(t *testing.T) {
+	t.Setenv("SERVER_OS", "linux")
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "no changes",
+			from: "a",
+			to:   "a",
+			want: "--- song 7 revision 1\n+++ song 7 revision current\n",
+		},
+		{
+			name: "replaced line",
+			from: "a\nb\nc",
+			to:   "a\nx\nc",
+			want: "--- song 7 revision 1\n+++ song 7 revision current\n@@ -1,3 +1,3 @@ verse 1\n a\n-b\n+x\n c\n",
+		},
+		{
+			name: "new verse",
+			from: "a",
+			to:   "a\n\nb",
+			want: "--- song 7 revision 1\n+++ song 7 revision current\n@@ -1,1 +1,3 @@ verse 2\n a\n+\n+b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops := diffLyricsLines(splitLyricsLines(test.from), splitLyricsLines(test.to))
+			diff := &entities.LyricsDiff{SongId: 7, From: lyricsRevisionLabel(1), To: lyricsRevisionLabel(CurrentLyrics), Hunks: buildDiffHunks(ops)}
+			if got := FormatUnifiedLyricsDiff(diff); got != test.want {
+				t.Errorf("unified diff = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
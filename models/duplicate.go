@@ -0,0 +1,245 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+var ErrMergeSameSong = errors.New("song can't be merged into itself")
+
+// normalizeTitleSQL возвращает выражение нормализации названия для значения expr.
+// Оно должно совпадать с выражением столбца songs.normalized_title из миграции 000012 и с entities.NormalizeSongTitle
+func normalizeTitleSQL(expr string) string {
+	return "lower(btrim(regexp_replace(regexp_replace(" + expr + ", '[^[:alnum:][:space:]]+', '', 'g'), '[[:space:]]+', ' ', 'g')))"
+}
+
+// SongExistsError возвращается, если изменение создало бы дубликат живой песни. Совместима с ErrSongExists
+type SongExistsError struct {
+	SongId int // существующая песня
+}
+
+func (e *SongExistsError) Error() string {
+	return fmt.Sprintf("%v: id %d", ErrSongExists, e.SongId)
+}
+
+func (e *SongExistsError) Unwrap() error {
+	return ErrSongExists
+}
+
+// FindSong ищет песню с таким же нормализованным названием у того же исполнителя
+func FindSong(title, group string) (int, error) {
+	return findSong(Db, title, group, 0)
+}
+
+// findSong ищет живую песню с таким же нормализованным названием у того же исполнителя, кроме песни exceptId
+func findSong(q querier, title, group string, exceptId int) (int, error) {
+	var id int
+	err := q.QueryRow(`SELECT songs.id FROM songs JOIN artists ON artists.id = songs.artist_id
+		WHERE artists.normalized_name = $1 AND songs.normalized_title = `+normalizeTitleSQL("$2::text")+` AND songs.deleted_at IS NULL AND songs.id <> $3
+		ORDER BY songs.id LIMIT 1`, entities.NormalizeArtistName(group), title, exceptId).Scan(&id)
+	if err != nil && err == sql.ErrNoRows {
+		return 0, ErrNoSongFound
+	} else if err != nil {
+		return 0, fmt.Errorf("error while finding song: %w", err)
+	}
+	return id, nil
+}
+
+// lockSongKey блокирует пару исполнитель-название до конца транзакции. Индекс по normalized_title не уникальный,
+// поэтому без блокировки два параллельных запроса могли бы одновременно не найти дубликат и добавить одну и ту же песню
+func lockSongKey(tx *sql.Tx, title, group string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1 || chr(0) || "+normalizeTitleSQL("$2::text")+"))", entities.NormalizeArtistName(group), title)
+	if err != nil {
+		return fmt.Errorf("error while locking song title: %w", err)
+	}
+	return nil
+}
+
+// checkSongKeyFree блокирует пару исполнитель-название так же, как AddSong, и проверяет, что у исполнителя нет другой живой песни
+// с таким названием. Нужна перед тем, как вернуть в библиотеку песню или ее старое название: иначе появился бы дубликат, который AddSong не допускает
+func checkSongKeyFree(tx *sql.Tx, id int, title, group string) error {
+	if err := lockSongKey(tx, title, group); err != nil {
+		return err
+	}
+	existing, err := findSong(tx, title, group, id)
+	if err == nil {
+		return &SongExistsError{SongId: existing}
+	} else if !errors.Is(err, ErrNoSongFound) {
+		return err
+	}
+	return nil
+}
+
+// maxDuplicateCandidates ограничивает число кандидатов в дубликаты для одной песни
+const maxDuplicateCandidates = 10
+
+// FindDuplicates возвращает пары песен, похожих на дубликаты: у одного или похожего исполнителя похожесть нормализованных
+// названий по триграммам не меньше threshold. Сначала идут точные совпадения, затем самые похожие пары
+func FindDuplicates(threshold float64, limit, offset int) ([]entities.DuplicatePair, error) {
+	// кандидаты для каждой песни выбираются по триграммному индексу на normalized_title, и только для них считается похожесть.
+	// Совпадающие непустые названия похожи полностью, поэтому точные совпадения тоже находятся оператором %
+	query := `SELECT a.id, a.title, a.group_name, length(COALESCE(a.lyrics, '')), COALESCE(a.link, ''),
+		b.id, b.title, b.group_name, b.lyrics_length, b.link, b.similarity, b.exact
+		FROM songs AS a CROSS JOIN LATERAL (
+			SELECT c.id, c.title, c.group_name, length(COALESCE(c.lyrics, '')) AS lyrics_length, COALESCE(c.link, '') AS link,
+			similarity(a.normalized_title, c.normalized_title) AS similarity,
+			a.artist_id = c.artist_id AND a.normalized_title = c.normalized_title AS exact
+			FROM songs AS c
+			WHERE c.normalized_title % a.normalized_title AND c.deleted_at IS NULL AND c.id > a.id
+			AND (c.artist_id = a.artist_id OR c.group_name % a.group_name)
+			ORDER BY exact DESC, similarity DESC, c.id LIMIT $3
+		) AS b
+		WHERE a.deleted_at IS NULL
+		ORDER BY b.exact DESC, b.similarity DESC, a.id, b.id LIMIT $1 OFFSET $2`
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setSimilarityThreshold(tx, threshold); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(query, limit, offset, maxDuplicateCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("error while finding duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := []entities.DuplicatePair{}
+	for rows.Next() {
+		var pair entities.DuplicatePair
+		err := rows.Scan(&pair.First.Id, &pair.First.Title, &pair.First.Group, &pair.First.LyricsLength, &pair.First.Link,
+			&pair.Second.Id, &pair.Second.Title, &pair.Second.Group, &pair.Second.LyricsLength, &pair.Second.Link,
+			&pair.Similarity, &pair.Exact)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return pairs, tx.Commit()
+}
+
+// MergeSongs объединяет песни mergeIds с песней keepId. У оставляемой песни сохраняется более длинный текст,
+// пустые ссылка и дата релиза берутся из объединяемых песен, теги объединяются, а записи плейлистов переносятся на нее.
+// Если у оставляемой песни нет альбома, ей достается место в альбоме первой объединяемой песни, у которой оно есть.
+// Объединенные песни переносятся в корзину, поэтому объединение можно отменить восстановлением из корзины
+func MergeSongs(keepId int, mergeIds []int, actor string) error {
+	ids := []int{keepId}
+	used := map[int]bool{keepId: true}
+	sources := []int{}
+	for _, id := range mergeIds {
+		if id == keepId {
+			return ErrMergeSameSong
+		}
+		if !used[id] {
+			used[id] = true
+			ids = append(ids, id)
+			sources = append(sources, id)
+		}
+	}
+	mergeIds = sources
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// песни блокируются в порядке id, чтобы параллельные объединения не блокировали друг друга взаимно
+	sort.Ints(ids)
+	for _, id := range ids {
+		if err := lockSongVersion(tx, id, AnyVersion, false); err != nil {
+			return fmt.Errorf("%w: id %d", err, id)
+		}
+	}
+
+	var keepAlbum sql.NullInt64
+	if err := tx.QueryRow("SELECT album_id FROM songs WHERE id = $1", keepId).Scan(&keepAlbum); err != nil {
+		return fmt.Errorf("error while getting song album: %w", err)
+	}
+
+	// вместе с полем у исходной песни берется и его происхождение
+	takeLyrics := "length(COALESCE(source.lyrics, '')) > length(COALESCE(songs.lyrics, ''))"
+	takeLink := "NULLIF(songs.link, '') IS NULL AND NULLIF(source.link, '') IS NOT NULL"
+	takeReleaseDate := "songs.release_date IS NULL AND source.release_date IS NOT NULL"
+	source := func(key, take string) string {
+		return fmt.Sprintf("'%s', CASE WHEN %s THEN source.metadata_sources->'%s' ELSE songs.metadata_sources->'%s' END", key, take, key, key)
+	}
+	for _, id := range mergeIds {
+		_, err := tx.Exec(`UPDATE songs SET
+			lyrics = CASE WHEN `+takeLyrics+` THEN source.lyrics ELSE songs.lyrics END,
+			link = COALESCE(NULLIF(songs.link, ''), NULLIF(source.link, '')),
+			release_date = COALESCE(songs.release_date, source.release_date),
+			metadata_sources = jsonb_strip_nulls(jsonb_build_object(`+source(entities.FieldReleaseDate, takeReleaseDate)+`, `+
+			source(entities.FieldLyrics, takeLyrics)+`, `+source(entities.FieldLink, takeLink)+`))
+			FROM songs AS source WHERE songs.id = $1 AND source.id = $2`, keepId, id)
+		if err != nil {
+			return fmt.Errorf("error while merging song: %w", err)
+		}
+
+		if keepAlbum.Valid {
+			continue
+		}
+		// место в альбоме освобождается до переноса, иначе нарушится уникальность (album_id, track_number)
+		var album, track sql.NullInt64
+		err = tx.QueryRow(`UPDATE songs SET album_id = NULL, track_number = NULL FROM (SELECT album_id, track_number FROM songs WHERE id = $1) AS old
+			WHERE songs.id = $1 AND songs.album_id IS NOT NULL RETURNING old.album_id, old.track_number`, id).Scan(&album, &track)
+		if err != nil && err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error while moving album track: %w", err)
+		}
+		_, err = tx.Exec("UPDATE songs SET album_id = $1, track_number = $2 WHERE id = $3", album, track, keepId)
+		if err != nil {
+			return fmt.Errorf("error while moving album track: %w", err)
+		}
+		keepAlbum = album
+	}
+
+	_, err = tx.Exec("INSERT INTO song_tags (song_id, tag_id) SELECT $1, tag_id FROM song_tags WHERE song_id = ANY($2) ON CONFLICT DO NOTHING", keepId, pq.Array(mergeIds))
+	if err != nil {
+		return fmt.Errorf("error while merging song tags: %w", err)
+	}
+	_, err = tx.Exec("UPDATE playlists SET updated_at = now() WHERE id IN (SELECT playlist_id FROM playlist_entries WHERE song_id = ANY($1))", pq.Array(mergeIds))
+	if err != nil {
+		return fmt.Errorf("error while updating playlists: %w", err)
+	}
+	_, err = tx.Exec("UPDATE playlist_entries SET song_id = $1 WHERE song_id = ANY($2)", keepId, pq.Array(mergeIds))
+	if err != nil {
+		return fmt.Errorf("error while moving playlist entries: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE songs SET deleted_at = now(), version = version + 1 WHERE id = ANY($1)", pq.Array(mergeIds))
+	if err != nil {
+		return fmt.Errorf("error while deleting merged songs: %w", err)
+	}
+	for _, id := range mergeIds {
+		if err := recordRevision(tx, id, ChangeDelete, actor); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE songs SET version = version + 1, updated_at = now() WHERE id = $1", keepId)
+	if err != nil {
+		return fmt.Errorf("error while updating song: %w", err)
+	}
+	if err := recordRevision(tx, keepId, ChangeMerge, actor); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	EnrichmentPending = "pending"
+	EnrichmentDone    = "done"
+	EnrichmentFailed  = "failed"
+)
+
+const (
+	enrichmentActor = "enrichment"
+	// enrichmentLease - на сколько песня закрепляется за обработчиком. Если обработчик не успел сообщить результат,
+	// например из-за перезапуска сервиса, по истечении этого времени песню возьмет другой обработчик
+	enrichmentLease = 5 * time.Minute
+)
+
+// EnrichmentJob - песня, которую обработчик должен обогатить
+type EnrichmentJob struct {
+	SongId   int
+	Title    string
+	Group    string
+	Attempts int // неудачных попыток до этой
+}
+
+func GetSongEnrichment(id int) (*entities.SongEnrichment, error) {
+	var enrichment entities.SongEnrichment
+	var message sql.NullString
+	var sources []byte
+	err := Db.QueryRow(`SELECT id, enrichment_status, enrichment_attempts, enrichment_error, enrichment_next_at, enriched_at, metadata_sources
+		FROM songs WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&enrichment.SongId, &enrichment.Status, &enrichment.Attempts,
+		&message, &enrichment.NextAttemptAt, &enrichment.EnrichedAt, &sources)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoSongFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting song enrichment: %w", err)
+	}
+	enrichment.Error = message.String
+	if err := json.Unmarshal(sources, &enrichment.Sources); err != nil {
+		return nil, fmt.Errorf("error while reading song sources: %w", err)
+	}
+	return &enrichment, nil
+}
+
+// RequestEnrichment ставит песню в очередь на повторное обогащение, в том числе уже обогащенную или с исчерпанными попытками
+func RequestEnrichment(id int) error {
+	result, err := Db.Exec(`UPDATE songs SET enrichment_status = $2, enrichment_attempts = 0, enrichment_error = NULL, enrichment_next_at = now()
+		WHERE id = $1 AND deleted_at IS NULL`, id, EnrichmentPending)
+	if err != nil {
+		return fmt.Errorf("error while requesting song enrichment: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoSongFound
+	}
+	return nil
+}
+
+// ClaimEnrichments закрепляет за обработчиками до limit песен, ожидающих обогащения, и возвращает их.
+// SKIP LOCKED позволяет нескольким экземплярам сервиса разбирать очередь, не получая одни и те же песни
+func ClaimEnrichments(limit int) ([]EnrichmentJob, error) {
+	rows, err := Db.Query(`UPDATE songs SET enrichment_next_at = now() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM songs WHERE enrichment_status = $1 AND enrichment_next_at <= now() AND deleted_at IS NULL
+			ORDER BY enrichment_next_at LIMIT $3 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, title, group_name, enrichment_attempts`, EnrichmentPending, enrichmentLease.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error while claiming songs for enrichment: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []EnrichmentJob{}
+	for rows.Next() {
+		var job EnrichmentJob
+		if err := rows.Scan(&job.SongId, &job.Title, &job.Group, &job.Attempts); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CompleteEnrichment сохраняет метаданные в песню. Дата выхода передается в формате YYYY-MM-DD, происхождение полей - в song.Sources.
+// Пустые значения не затирают уже заполненные поля. Если песню успели удалить, данные не сохраняются
+func CompleteEnrichment(id int, song *entities.Song) error {
+	sources, err := marshalSources(song.Sources)
+	if err != nil {
+		return err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE songs SET release_date = COALESCE(NULLIF($2::varchar, '')::date, release_date),
+		lyrics = COALESCE(NULLIF($3::text, ''), lyrics), link = COALESCE(NULLIF($4::varchar, ''), link), metadata_sources = metadata_sources || $7::jsonb,
+		enrichment_status = $5, enrichment_attempts = enrichment_attempts + 1, enrichment_error = NULL, enrichment_next_at = NULL, enriched_at = now(),
+		version = version + 1, updated_at = now()
+		WHERE id = $1 AND enrichment_status = $6 AND deleted_at IS NULL`, id, song.ReleaseDate, song.Lyrics, song.Link, EnrichmentDone, EnrichmentPending, sources)
+	if err != nil {
+		return fmt.Errorf("error while saving song enrichment: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoSongFound
+	}
+
+	if err := recordRevision(tx, id, ChangeUpdate, enrichmentActor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// RescheduleEnrichment откладывает обогащение песни до at. countAttempt учитывает неудачную попытку;
+// попытка не учитывается, если к API даже не обращались, например пока автомат перед ним разомкнут
+func RescheduleEnrichment(id int, message string, at time.Time, countAttempt bool) error {
+	attempts := 0
+	if countAttempt {
+		attempts = 1
+	}
+	_, err := Db.Exec(`UPDATE songs SET enrichment_attempts = enrichment_attempts + $2, enrichment_error = $3, enrichment_next_at = $4
+		WHERE id = $1 AND enrichment_status = $5`, id, attempts, message, at, EnrichmentPending)
+	if err != nil {
+		return fmt.Errorf("error while rescheduling song enrichment: %w", err)
+	}
+	return nil
+}
+
+// FailEnrichment прекращает попытки обогатить песню. Запустить обогащение заново можно через RequestEnrichment
+func FailEnrichment(id int, message string) error {
+	_, err := Db.Exec(`UPDATE songs SET enrichment_status = $2, enrichment_attempts = enrichment_attempts + 1, enrichment_error = $3, enrichment_next_at = NULL
+		WHERE id = $1 AND enrichment_status = $4`, id, EnrichmentFailed, message, EnrichmentPending)
+	if err != nil {
+		return fmt.Errorf("error while saving song enrichment failure: %w", err)
+	}
+	return nil
+}
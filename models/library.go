@@ -2,49 +2,190 @@ package models
 
 import (
 	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
-func formatSongReleaseDate(song *entities.Song) error {
-	releaseDate, err := time.Parse(time.RFC3339, song.ReleaseDate)
-	if err != nil {
-		return err
+var ErrInvalidSort = errors.New("invalid sort parameter")
+
+// librarySortColumns сопоставляет допустимые поля сортировки из запроса со столбцами таблицы songs.
+// Только эти значения попадают в ORDER BY, поэтому пользовательский ввод не подставляется в запрос напрямую
+var librarySortColumns = map[string]string{
+	"id":          "id",
+	"title":       "title",
+	"group":       "group_name",
+	"releaseDate": "release_date",
+}
+
+// LibraryFilter содержит фильтры выдачи библиотеки. Пустое поле означает отсутствие фильтра, дата передается в формате YYYY-MM-DD
+type LibraryFilter struct {
+	Title        string
+	Group        string
+	ReleaseDate  string
+	ReleasedFrom string // включительно
+	ReleasedTo   string // включительно
+	ReleaseYear  int    // 0 означает отсутствие фильтра
+	Lyrics       string
+	LyricsQuery  string // полнотекстовый запрос в синтаксисе websearch_to_tsquery
+	Link         string
+	AlbumId      int      // 0 означает отсутствие фильтра
+	Tags         []string // песни хотя бы с одним из тегов или, если AllTags, со всеми тегами сразу
+	AllTags      bool
+	Fuzzy        bool    // нечеткое сравнение title и group по триграммам вместо поиска по префиксу
+	Threshold    float64 // минимальная похожесть (0..1) в нечетком режиме
+}
+
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// ParseLibrarySort разбирает параметр вида "-releaseDate,title,group". Минус перед полем означает сортировку по убыванию
+func ParseLibrarySort(sort string) ([]SortField, error) {
+	fields := []SortField{}
+	if sort == "" {
+		return fields, nil
 	}
 
-	song.ReleaseDate = releaseDate.Format("02.01.2006")
-	return nil
+	used := map[string]bool{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+
+		column, ok := librarySortColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, name)
+		}
+		if used[column] {
+			return nil, fmt.Errorf("%w: field %q used more than once", ErrInvalidSort, name)
+		}
+		used[column] = true
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+	return fields, nil
 }
 
-func GetLibrary(title, group, releaseDate, lyrics, link string, limit, offset int) ([]entities.Song, error) {
-	query := `SELECT id, title, group_name, release_date, lyrics, link FROM songs WHERE 1=1`
-	args := []interface{}{} // переменная хранит параметры фильтрации и пагинации. Тип переменной []interface{}, так как аргументы имеют типы string и int
+// orderFields дополняет сортировку полем id, если оно не указано явно, чтобы порядок был стабильным
+func orderFields(fields []SortField) []SortField {
+	for _, field := range fields {
+		if field.Column == "id" {
+			return fields
+		}
+	}
+	return append(append([]SortField{}, fields...), SortField{Column: "id"})
+}
 
-	if title != "" {
+func buildOrderBy(fields []SortField) string {
+	parts := []string{}
+	for _, field := range orderFields(fields) {
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, field.Column+" "+direction)
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// ParseReleaseDate переводит дату из формата API DD.MM.YYYY в формат бд YYYY-MM-DD. Пустая строка остается пустой:
+// в фильтрах она означает отсутствие фильтра, у песни - отсутствие даты
+func ParseReleaseDate(releaseDate string) (string, error) {
+	if releaseDate == "" {
+		return "", nil
+	}
+	date, err := time.Parse("02.01.2006", releaseDate)
+	if err != nil {
+		return "", err
+	}
+	return date.Format("2006-01-02"), nil
+}
+
+// buildLibraryFilter формирует условия WHERE по фильтрам библиотеки. Используется всеми запросами к библиотеке,
+// чтобы постраничная выдача, выдача по курсору и подсчет количества фильтровали одинаково
+func buildLibraryFilter(filter LibraryFilter) (string, []interface{}) {
+	query := " WHERE deleted_at IS NULL" // песни из корзины не попадают в библиотеку
+	args := []interface{}{}              // переменная хранит параметры фильтрации и пагинации. Тип переменной []interface{}, так как аргументы имеют типы string и int
+
+	if filter.Title != "" && filter.Fuzzy {
+		query += " AND title % $" + fmt.Sprint(len(args)+1)
+		args = append(args, filter.Title)
+	} else if filter.Title != "" {
 		query += " AND title ILIKE $" + fmt.Sprint(len(args)+1)
-		args = append(args, title+"%")
+		args = append(args, filter.Title+"%")
 	}
-	if group != "" {
+	if filter.Group != "" && filter.Fuzzy {
+		query += " AND group_name % $" + fmt.Sprint(len(args)+1)
+		args = append(args, filter.Group)
+	} else if filter.Group != "" {
 		query += " AND group_name ILIKE $" + fmt.Sprint(len(args)+1)
-		args = append(args, group+"%")
+		args = append(args, filter.Group+"%")
 	}
-	if releaseDate != "" {
+	if filter.ReleaseDate != "" {
 		query += " AND release_date = $" + fmt.Sprint(len(args)+1)
-		args = append(args, releaseDate)
+		args = append(args, filter.ReleaseDate)
+	}
+	// диапазоны задаются сравнениями по самому столбцу, а не через EXTRACT, чтобы использовался индекс idx_songs_release_date
+	if filter.ReleasedFrom != "" {
+		query += " AND release_date >= $" + fmt.Sprint(len(args)+1)
+		args = append(args, filter.ReleasedFrom)
+	}
+	if filter.ReleasedTo != "" {
+		query += " AND release_date <= $" + fmt.Sprint(len(args)+1)
+		args = append(args, filter.ReleasedTo)
+	}
+	if filter.ReleaseYear != 0 {
+		query += fmt.Sprintf(" AND release_date >= $%d AND release_date < $%d", len(args)+1, len(args)+2)
+		args = append(args, fmt.Sprintf("%04d-01-01", filter.ReleaseYear), fmt.Sprintf("%04d-01-01", filter.ReleaseYear+1))
 	}
-	if lyrics != "" {
+	if filter.Lyrics != "" {
 		query += " AND lyrics ILIKE $" + fmt.Sprint(len(args)+1)
-		args = append(args, "%"+lyrics+"%")
+		args = append(args, "%"+filter.Lyrics+"%")
 	}
-	if link != "" {
+	if filter.Link != "" {
 		query += " AND link ILIKE $" + fmt.Sprint(len(args)+1)
-		args = append(args, "%"+link+"%")
+		args = append(args, "%"+filter.Link+"%")
 	}
+	if filter.AlbumId != 0 {
+		query += " AND album_id = $" + fmt.Sprint(len(args)+1)
+		args = append(args, filter.AlbumId)
+	}
+	if len(filter.Tags) != 0 {
+		var condition string
+		condition, args = buildTagsFilter(filter.Tags, filter.AllTags, args)
+		query += condition
+	}
+	if filter.LyricsQuery != "" {
+		query += " AND " + lyricsVector + " @@ websearch_to_tsquery('english', $" + fmt.Sprint(len(args)+1) + ")"
+		args = append(args, filter.LyricsQuery)
+	}
+	return query, args
+}
 
-	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
-	args = append(args, limit, offset)
+// scanSong читает столбцы песни id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number
+// и следующие за ними столбцы extra. Пустые release_date, lyrics и link хранятся как NULL и отдаются пустой строкой
+func scanSong(row interface{ Scan(...interface{}) error }, song *entities.Song, extra ...interface{}) error {
+	var releaseDate sql.NullTime
+	var lyrics, link sql.NullString
+	dest := []interface{}{&song.Id, &song.Title, &song.Group, &song.ArtistId, &releaseDate, &lyrics, &link, &song.AlbumId, &song.TrackNumber}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
 
-	rows, err := Db.Query(query, args...)
+	song.ReleaseDate = ""
+	if releaseDate.Valid {
+		song.ReleaseDate = releaseDate.Time.Format("02.01.2006")
+	}
+	song.Lyrics = lyrics.String
+	song.Link = link.String
+	return nil
+}
+
+func queryLibrary(q querier, query string, args []interface{}) ([]entities.Song, error) {
+	rows, err := q.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +194,9 @@ func GetLibrary(title, group, releaseDate, lyrics, link string, limit, offset in
 	library := []entities.Song{}
 	for rows.Next() {
 		var song entities.Song
-		err := rows.Scan(&song.Id, &song.Title, &song.Group, &song.ReleaseDate, &song.Lyrics, &song.Link)
-		if err != nil {
+		if err := scanSong(rows, &song); err != nil {
 			return nil, err
 		}
-		formatSongReleaseDate(&song)
 		library = append(library, song)
 	}
 
@@ -66,3 +205,114 @@ func GetLibrary(title, group, releaseDate, lyrics, link string, limit, offset in
 	}
 	return library, nil
 }
+
+func GetLibrary(filter LibraryFilter, sort []SortField, limit, offset int) ([]entities.Song, error) {
+	where, args := buildLibraryFilter(filter)
+	query := `SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number FROM songs` + where
+
+	if len(sort) == 0 && isSimilarityOrdered(filter) {
+		var orderBy string
+		orderBy, args = buildSimilarityOrderBy(filter, args)
+		query += orderBy
+	} else {
+		query += buildOrderBy(sort)
+	}
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	var library []entities.Song
+	err := withLibraryFilter(filter, func(q querier) error {
+		var err error
+		library, err = queryLibrary(q, query, args)
+		return err
+	})
+	return library, err
+}
+
+// CountLibrary возвращает количество песен, подходящих под фильтры
+func CountLibrary(filter LibraryFilter) (int, error) {
+	where, args := buildLibraryFilter(filter)
+	var total int
+	err := withLibraryFilter(filter, func(q querier) error {
+		return q.QueryRow(`SELECT COUNT(*) FROM songs`+where, args...).Scan(&total)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error while counting library: %w", err)
+	}
+	return total, nil
+}
+
+// GetLibraryByCursor возвращает страницу библиотеки, следующую за позицией курсора (keyset-пагинация).
+// Пустой cursor означает первую страницу. Вторым значением возвращается курсор следующей страницы или пустая строка, если страниц больше нет
+func GetLibraryByCursor(filter LibraryFilter, sort []SortField, cursor string, limit int) ([]entities.Song, string, error) {
+	where, args := buildLibraryFilter(filter)
+	query := `SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number FROM songs` + where
+
+	if cursor != "" {
+		position, err := decodeLibraryCursor(cursor, sort)
+		if err != nil {
+			return nil, "", err
+		}
+		var condition string
+		condition, args = buildKeysetCondition(sort, position, args)
+		query += " AND " + condition
+	}
+
+	query += buildOrderBy(sort)
+	query += fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	args = append(args, limit+1) // лишняя строка показывает, есть ли следующая страница
+
+	var library []entities.Song
+	err := withLibraryFilter(filter, func(q querier) error {
+		var err error
+		library, err = queryLibrary(q, query, args)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(library) <= limit {
+		return library, "", nil
+	}
+
+	library = library[:limit]
+	nextCursor, err := encodeLibraryCursor(sort, &library[limit-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return library, nextCursor, nil
+}
+
+// ExportLibrary передает в fn песни библиотеки по одной по мере чтения строк из бд, не собирая всю выдачу в память.
+// Фильтры и сортировка такие же, как в GetLibrary. Ошибка fn прерывает выгрузку и возвращается как есть
+func ExportLibrary(filter LibraryFilter, sort []SortField, fn func(song *entities.Song) error) error {
+	where, args := buildLibraryFilter(filter)
+	query := `SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number FROM songs` + where
+
+	if len(sort) == 0 && isSimilarityOrdered(filter) {
+		var orderBy string
+		orderBy, args = buildSimilarityOrderBy(filter, args)
+		query += orderBy
+	} else {
+		query += buildOrderBy(sort)
+	}
+
+	return withLibraryFilter(filter, func(q querier) error {
+		rows, err := q.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("error while exporting library: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var song entities.Song
+			if err := scanSong(rows, &song); err != nil {
+				return err
+			}
+			if err := fn(&song); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch document")
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// SongDocument - изменяемые поля песни в виде JSON-документа, к которому применяются JSON Merge Patch (RFC 7396)
+// и JSON Patch (RFC 6902). Ключи совпадают с полями JSON песни, nil соответствует пустому полю (null).
+// Остальные поля песни (id, artistId, albumId, trackNumber) только для чтения и в документ не входят
+type SongDocument map[string]*string
+
+func NewSongDocument(song *entities.Song) SongDocument {
+	doc := SongDocument{}
+	for field, value := range map[string]string{
+		"title":       song.Title,
+		"group":       song.Group,
+		"releaseDate": song.ReleaseDate,
+		"lyrics":      song.Lyrics,
+		"link":        song.Link,
+	} {
+		doc[field] = nil
+		if value != "" {
+			doc[field] = &value
+		}
+	}
+	return doc
+}
+
+// Equal сообщает, совпадают ли значения всех полей документов. Пустая строка и null считаются равными
+func (doc SongDocument) Equal(other SongDocument) bool {
+	for field, value := range doc {
+		if !equalDocumentValues(value, other[field]) {
+			return false
+		}
+	}
+	return len(doc) == len(other)
+}
+
+// Song возвращает песню с полями документа. Пустые поля становятся пустыми строками
+func (doc SongDocument) Song() entities.Song {
+	value := func(field string) string {
+		if doc[field] == nil {
+			return ""
+		}
+		return *doc[field]
+	}
+	return entities.Song{
+		Title:       value("title"),
+		Group:       value("group"),
+		ReleaseDate: value("releaseDate"),
+		Lyrics:      value("lyrics"),
+		Link:        value("link"),
+	}
+}
+
+// field разбирает JSON Pointer (RFC 6901) на поле документа. У документа песни только один уровень вложенности
+func (doc SongDocument) field(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Count(pointer, "/") != 1 {
+		return "", fmt.Errorf("%w: path %q doesn't point to a song field", ErrInvalidPatch, pointer)
+	}
+	field := strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:])
+	if _, ok := doc[field]; !ok {
+		return "", fmt.Errorf("%w: unknown or read-only field %q", ErrInvalidPatch, field)
+	}
+	return field, nil
+}
+
+// decodeDocumentValue разбирает значение поля документа: строку или null
+func decodeDocumentValue(field string, raw json.RawMessage) (*string, error) {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("%w: value of %q must be a string or null", ErrInvalidPatch, field)
+	}
+	return &value, nil
+}
+
+// ApplyMergePatch применяет JSON Merge Patch: отсутствующее поле не меняется, null очищает поле
+func ApplyMergePatch(doc SongDocument, patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return fmt.Errorf("%w: merge patch must be a JSON object", ErrInvalidPatch)
+	}
+
+	for field, raw := range fields {
+		if _, ok := doc[field]; !ok {
+			return fmt.Errorf("%w: unknown or read-only field %q", ErrInvalidPatch, field)
+		}
+		value, err := decodeDocumentValue(field, raw)
+		if err != nil {
+			return err
+		}
+		doc[field] = value
+	}
+	return nil
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ApplyJSONPatch применяет операции JSON Patch по порядку. Все поля документа всегда существуют,
+// поэтому remove очищает поле, а add и replace одинаково задают его значение
+func ApplyJSONPatch(doc SongDocument, patch []byte) error {
+	var operations []patchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil || operations == nil {
+		return fmt.Errorf("%w: JSON Patch must be an array of operations", ErrInvalidPatch)
+	}
+
+	for i, operation := range operations {
+		if err := applyPatchOperation(doc, operation); err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func applyPatchOperation(doc SongDocument, operation patchOperation) error {
+	field, err := doc.field(operation.Path)
+	if err != nil {
+		return err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return fmt.Errorf("%w: %s operation requires value", ErrInvalidPatch, operation.Op)
+		}
+		value, err := decodeDocumentValue(field, operation.Value)
+		if err != nil {
+			return err
+		}
+		if operation.Op != "test" {
+			doc[field] = value
+		} else if !equalDocumentValues(doc[field], value) {
+			return fmt.Errorf("%w: value of %q differs", ErrPatchTestFailed, field)
+		}
+	case "remove":
+		doc[field] = nil
+	case "move", "copy":
+		from, err := doc.field(operation.From)
+		if err != nil {
+			return err
+		}
+		value := doc[from]
+		if operation.Op == "move" {
+			doc[from] = nil
+		}
+		doc[field] = value
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, operation.Op)
+	}
+	return nil
+}
+
+// equalDocumentValues сравнивает значения полей для операции test. Пустая строка и null равны: GET отдает пустое поле как "",
+// а в документе оно хранится как nil, поэтому test со значением, которое клиент только что прочитал, должен проходить
+func equalDocumentValues(a, b *string) bool {
+	value := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+	return value(a) == value(b)
+}
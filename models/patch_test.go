@@ -0,0 +1,196 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	song := entities.Song{Title: "Hysteria", Group: "Muse", Link: "https://example.com/hysteria"}
+
+	tests := []struct {
+		name  string
+		patch string
+		want  entities.Song
+		err   error
+	}{
+		{
+			name:  "replace",
+			patch: `[{"op": "replace", "path": "/lyrics", "value": "It's bugging me"}]`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse", Lyrics: "It's bugging me", Link: "https://example.com/hysteria"},
+		},
+		{
+			name:  "test empty field with empty string",
+			patch: `[{"op": "test", "path": "/lyrics", "value": ""}, {"op": "add", "path": "/lyrics", "value": "It's bugging me"}]`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse", Lyrics: "It's bugging me", Link: "https://example.com/hysteria"},
+		},
+		{
+			name:  "test empty field with null",
+			patch: `[{"op": "test", "path": "/lyrics", "value": null}]`,
+			want:  song,
+		},
+		{
+			name:  "test filled field with null",
+			patch: `[{"op": "test", "path": "/link", "value": null}]`,
+			err:   ErrPatchTestFailed,
+		},
+		{
+			name:  "test filled field with empty string",
+			patch: `[{"op": "test", "path": "/title", "value": ""}]`,
+			err:   ErrPatchTestFailed,
+		},
+		{
+			name:  "remove required field",
+			patch: `[{"op": "remove", "path": "/title"}]`,
+			want:  entities.Song{Group: "Muse", Link: "https://example.com/hysteria"},
+		},
+		{
+			name:  "move",
+			patch: `[{"op": "move", "from": "/link", "path": "/lyrics"}]`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse", Lyrics: "https://example.com/hysteria"},
+		},
+		{
+			name:  "empty operations list",
+			patch: `[]`,
+			want:  song,
+		},
+		{
+			name:  "null body",
+			patch: `null`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "object body",
+			patch: `{"op": "remove", "path": "/title"}`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "read-only field",
+			patch: `[{"op": "replace", "path": "/id", "value": "1"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "replace without value",
+			patch: `[{"op": "replace", "path": "/title"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "unknown operation",
+			patch: `[{"op": "append", "path": "/lyrics", "value": "x"}]`,
+			err:   ErrInvalidPatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := NewSongDocument(&song)
+			err := ApplyJSONPatch(doc, []byte(test.patch))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ApplyJSONPatch() error = %v, want %v", err, test.err)
+			}
+			if err == nil && !reflect.DeepEqual(doc.Song(), test.want) {
+				t.Errorf("patched song = %+v, want %+v", doc.Song(), test.want)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	song := entities.Song{Title: "Hysteria", Group: "Muse", Lyrics: "It's bugging me"}
+
+	tests := []struct {
+		name  string
+		patch string
+		want  entities.Song
+		err   error
+	}{
+		{
+			name:  "set field",
+			patch: `{"link": "https://example.com/hysteria"}`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse", Lyrics: "It's bugging me", Link: "https://example.com/hysteria"},
+		},
+		{
+			name:  "null clears field",
+			patch: `{"lyrics": null}`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse"},
+		},
+		{
+			name:  "empty string clears field",
+			patch: `{"lyrics": ""}`,
+			want:  entities.Song{Title: "Hysteria", Group: "Muse"},
+		},
+		{
+			name:  "remove required field",
+			patch: `{"title": null}`,
+			want:  entities.Song{Group: "Muse", Lyrics: "It's bugging me"},
+		},
+		{
+			name:  "empty object",
+			patch: `{}`,
+			want:  song,
+		},
+		{
+			name:  "array body",
+			patch: `[{"op": "remove", "path": "/title"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "null body",
+			patch: `null`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "read-only field",
+			patch: `{"id": "1"}`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "not a string",
+			patch: `{"link": 5}`,
+			err:   ErrInvalidPatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := NewSongDocument(&song)
+			err := ApplyMergePatch(doc, []byte(test.patch))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ApplyMergePatch() error = %v, want %v", err, test.err)
+			}
+			if err == nil && !reflect.DeepEqual(doc.Song(), test.want) {
+				t.Errorf("patched song = %+v, want %+v", doc.Song(), test.want)
+			}
+		})
+	}
+}
+
+func TestSongDocumentEqual(t *testing.T) {
+	song := entities.Song{Title: "Hysteria", Group: "Muse"}
+	empty := ""
+	changed := "Time Is Running Out"
+
+	tests := []struct {
+		name  string
+		field string
+		value *string
+		want  bool
+	}{
+		{name: "same value", field: "title", value: &song.Title, want: true},
+		{name: "empty string instead of null", field: "lyrics", value: &empty, want: true},
+		{name: "changed value", field: "title", value: &changed, want: false},
+		{name: "cleared value", field: "group", value: nil, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := NewSongDocument(&song)
+			doc[test.field] = test.value
+			if got := doc.Equal(NewSongDocument(&song)); got != test.want {
+				t.Errorf("Equal() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
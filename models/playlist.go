@@ -0,0 +1,299 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoPlaylistFound      = errors.New("no playlist found with provided id")
+	ErrNoPlaylistEntryFound = errors.New("no entry found with provided id in the playlist")
+)
+
+func GetPlaylists(limit, offset int) ([]entities.Playlist, error) {
+	rows, err := Db.Query(`SELECT playlists.id, playlists.name, COUNT(playlist_entries.id) FROM playlists
+		LEFT JOIN playlist_entries ON playlist_entries.playlist_id = playlists.id
+		GROUP BY playlists.id ORDER BY playlists.id LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting playlists: %w", err)
+	}
+	defer rows.Close()
+
+	playlists := []entities.Playlist{}
+	for rows.Next() {
+		var playlist entities.Playlist
+		if err := rows.Scan(&playlist.Id, &playlist.Name, &playlist.Songs); err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, playlist)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+// GetPlaylist возвращает плейлист вместе с записями и песнями в порядке позиций
+func GetPlaylist(id int) (*entities.Playlist, error) {
+	var playlist entities.Playlist
+	err := Db.QueryRow("SELECT id, name FROM playlists WHERE id = $1", id).Scan(&playlist.Id, &playlist.Name)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoPlaylistFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting playlist: %w", err)
+	}
+
+	rows, err := Db.Query(`SELECT songs.id, songs.title, songs.group_name, songs.artist_id, songs.release_date, songs.lyrics, songs.link, songs.album_id, songs.track_number,
+		playlist_entries.id, playlist_entries.position
+		FROM playlist_entries JOIN songs ON songs.id = playlist_entries.song_id
+		WHERE playlist_entries.playlist_id = $1 ORDER BY playlist_entries.position`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting playlist entries: %w", err)
+	}
+	defer rows.Close()
+
+	playlist.Entries = []entities.PlaylistEntry{}
+	for rows.Next() {
+		var entry entities.PlaylistEntry
+		var song entities.Song
+		if err := scanSong(rows, &song, &entry.Id, &entry.Position); err != nil {
+			return nil, err
+		}
+		entry.SongId = song.Id
+		entry.Song = &song
+		playlist.Entries = append(playlist.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	playlist.Songs = len(playlist.Entries)
+	return &playlist, nil
+}
+
+func AddPlaylist(playlist *entities.Playlist) error {
+	err := Db.QueryRow("INSERT INTO playlists (name) VALUES ($1) RETURNING id", playlist.Name).Scan(&playlist.Id)
+	if err != nil {
+		return fmt.Errorf("error while adding playlist: %w", err)
+	}
+	return nil
+}
+
+func RenamePlaylist(id int, name string) error {
+	result, err := Db.Exec("UPDATE playlists SET name = $1, updated_at = now() WHERE id = $2", name, id)
+	if err != nil {
+		return fmt.Errorf("error while renaming playlist: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoPlaylistFound
+	}
+	return nil
+}
+
+func DeletePlaylist(id int) error {
+	result, err := Db.Exec("DELETE FROM playlists WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error while deleting playlist: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoPlaylistFound
+	}
+	return nil
+}
+
+// lockPlaylist блокирует плейлист до конца транзакции, чтобы параллельные изменения позиций не пересекались,
+// и возвращает количество записей в нем
+func lockPlaylist(tx *sql.Tx, id int) (int, error) {
+	var playlistId int
+	err := tx.QueryRow("SELECT id FROM playlists WHERE id = $1 FOR UPDATE", id).Scan(&playlistId)
+	if err != nil && err == sql.ErrNoRows {
+		return 0, ErrNoPlaylistFound
+	} else if err != nil {
+		return 0, fmt.Errorf("error while locking playlist: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM playlist_entries WHERE playlist_id = $1", id).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error while counting playlist entries: %w", err)
+	}
+	return count, nil
+}
+
+func touchPlaylist(tx *sql.Tx, id int) error {
+	_, err := tx.Exec("UPDATE playlists SET updated_at = now() WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("error while updating playlist: %w", err)
+	}
+	return nil
+}
+
+// AddPlaylistEntry вставляет песню на указанную позицию, сдвигая последующие записи.
+// Позиция 0 или позиция за концом плейлиста означает добавление в конец
+func AddPlaylistEntry(playlistId int, entry *entities.PlaylistEntry) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count, err := lockPlaylist(tx, playlistId)
+	if err != nil {
+		return err
+	}
+	if entry.Position < 1 || entry.Position > count+1 {
+		entry.Position = count + 1
+	}
+
+	_, err = tx.Exec("UPDATE playlist_entries SET position = position + 1 WHERE playlist_id = $1 AND position >= $2", playlistId, entry.Position)
+	if err != nil {
+		return fmt.Errorf("error while shifting playlist entries: %w", err)
+	}
+
+	// песню из корзины добавить нельзя, поэтому вставка идет только для песни без deleted_at
+	err = tx.QueryRow("INSERT INTO playlist_entries (playlist_id, song_id, position) SELECT $1, id, $3 FROM songs WHERE id = $2 AND deleted_at IS NULL RETURNING id",
+		playlistId, entry.SongId, entry.Position).Scan(&entry.Id)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoSongFound
+	} else if err != nil {
+		return fmt.Errorf("error while adding playlist entry: %w", err)
+	}
+
+	if err := touchPlaylist(tx, playlistId); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// MovePlaylistEntry переносит запись на новую позицию. Позиция за концом плейлиста означает перенос в конец
+func MovePlaylistEntry(playlistId, entryId, position int) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count, err := lockPlaylist(tx, playlistId)
+	if err != nil {
+		return err
+	}
+	if position > count {
+		position = count
+	}
+
+	var current int
+	err = tx.QueryRow("SELECT position FROM playlist_entries WHERE id = $1 AND playlist_id = $2", entryId, playlistId).Scan(&current)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoPlaylistEntryFound
+	} else if err != nil {
+		return fmt.Errorf("error while getting playlist entry: %w", err)
+	}
+
+	if position < current {
+		_, err = tx.Exec("UPDATE playlist_entries SET position = position + 1 WHERE playlist_id = $1 AND position >= $2 AND position < $3", playlistId, position, current)
+	} else if position > current {
+		_, err = tx.Exec("UPDATE playlist_entries SET position = position - 1 WHERE playlist_id = $1 AND position > $2 AND position <= $3", playlistId, current, position)
+	}
+	if err != nil {
+		return fmt.Errorf("error while shifting playlist entries: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE playlist_entries SET position = $1 WHERE id = $2", position, entryId)
+	if err != nil {
+		return fmt.Errorf("error while moving playlist entry: %w", err)
+	}
+
+	if err := touchPlaylist(tx, playlistId); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// RemovePlaylistEntry удаляет запись и сдвигает последующие записи, чтобы позиции шли без пропусков
+func RemovePlaylistEntry(playlistId, entryId int) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockPlaylist(tx, playlistId); err != nil {
+		return err
+	}
+
+	var position int
+	err = tx.QueryRow("DELETE FROM playlist_entries WHERE id = $1 AND playlist_id = $2 RETURNING position", entryId, playlistId).Scan(&position)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoPlaylistEntryFound
+	} else if err != nil {
+		return fmt.Errorf("error while removing playlist entry: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE playlist_entries SET position = position - 1 WHERE playlist_id = $1 AND position > $2", playlistId, position)
+	if err != nil {
+		return fmt.Errorf("error while shifting playlist entries: %w", err)
+	}
+
+	if err := touchPlaylist(tx, playlistId); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// removeSongFromPlaylists удаляет все записи песни из плейлистов и заново нумерует оставшиеся записи затронутых плейлистов
+func removeSongFromPlaylists(tx *sql.Tx, songId int) error {
+	rows, err := tx.Query("DELETE FROM playlist_entries WHERE song_id = $1 RETURNING playlist_id", songId)
+	if err != nil {
+		return fmt.Errorf("error while removing song from playlists: %w", err)
+	}
+	playlistIds := []int{}
+	used := map[int]bool{}
+	for rows.Next() {
+		var playlistId int
+		if err := rows.Scan(&playlistId); err != nil {
+			rows.Close()
+			return err
+		}
+		if !used[playlistId] {
+			used[playlistId] = true
+			playlistIds = append(playlistIds, playlistId)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, playlistId := range playlistIds {
+		_, err := tx.Exec(`UPDATE playlist_entries SET position = numbered.position
+			FROM (SELECT id, row_number() OVER (ORDER BY position) AS position FROM playlist_entries WHERE playlist_id = $1) AS numbered
+			WHERE playlist_entries.id = numbered.id`, playlistId)
+		if err != nil {
+			return fmt.Errorf("error while renumbering playlist entries: %w", err)
+		}
+		if err := touchPlaylist(tx, playlistId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
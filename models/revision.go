@@ -0,0 +1,162 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrNoRevisionFound = errors.New("no revision found for the song")
+
+const (
+	ChangeCreate   = "create"
+	ChangeUpdate   = "update"
+	ChangePatch    = "patch"
+	ChangeRestore  = "restore"
+	ChangeDelete   = "delete"   // перенос в корзину
+	ChangeUndelete = "undelete" // восстановление из корзины
+	ChangePurge    = "purge"    // окончательное удаление
+	ChangeMerge    = "merge"    // объединение с дубликатами
+)
+
+// recordRevision сохраняет текущее состояние песни как новую ревизию. Вызывается в той же транзакции, что и изменение:
+// строка песни к этому моменту уже заблокирована изменением, поэтому номера ревизий одной песни не пересекаются
+func recordRevision(tx *sql.Tx, songId int, changeType, actor string) error {
+	_, err := tx.Exec(`INSERT INTO song_revisions (song_id, revision, title, group_name, artist_id, release_date, lyrics, link, change_type, actor)
+		SELECT id, COALESCE((SELECT MAX(revision) FROM song_revisions WHERE song_id = $1), 0) + 1, title, group_name, artist_id, release_date, lyrics, link, $2, $3
+		FROM songs WHERE id = $1`, songId, changeType, actor)
+	if err != nil {
+		return fmt.Errorf("error while recording song revision: %w", err)
+	}
+	return nil
+}
+
+// updateSongsWithRevisions выполняет UPDATE songs ... RETURNING id и записывает ревизию update каждой измененной песни.
+// Возвращает количество измененных песен
+func updateSongsWithRevisions(tx *sql.Tx, actor, query string, args ...interface{}) (int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := recordRevision(tx, id, ChangeUpdate, actor); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func scanRevision(row interface{ Scan(...interface{}) error }, revision *entities.SongRevision) error {
+	var releaseDate sql.NullTime
+	var lyrics, link sql.NullString
+	err := row.Scan(&revision.SongId, &revision.Revision, &revision.Song.Title, &revision.Song.Group, &revision.Song.ArtistId,
+		&releaseDate, &lyrics, &link, &revision.ChangeType, &revision.Actor, &revision.ChangedAt)
+	if err != nil {
+		return err
+	}
+
+	revision.Song.Id = revision.SongId
+	revision.Song.Lyrics = lyrics.String
+	revision.Song.Link = link.String
+	revision.Song.ReleaseDate = ""
+	if releaseDate.Valid {
+		revision.Song.ReleaseDate = releaseDate.Time.Format("02.01.2006")
+	}
+	return nil
+}
+
+// GetSongRevisions возвращает историю изменений песни, начиная с последнего. История удаленной песни тоже доступна
+func GetSongRevisions(songId int) ([]entities.SongRevision, error) {
+	rows, err := Db.Query(`SELECT song_id, revision, title, group_name, artist_id, release_date, lyrics, link, change_type, actor, changed_at
+		FROM song_revisions WHERE song_id = $1 ORDER BY revision DESC`, songId)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting song revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []entities.SongRevision{}
+	for rows.Next() {
+		var revision entities.SongRevision
+		if err := scanRevision(rows, &revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		if err := checkSongExists(songId); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+func GetSongRevision(songId, revisionNumber int) (*entities.SongRevision, error) {
+	var revision entities.SongRevision
+	row := Db.QueryRow(`SELECT song_id, revision, title, group_name, artist_id, release_date, lyrics, link, change_type, actor, changed_at
+		FROM song_revisions WHERE song_id = $1 AND revision = $2`, songId, revisionNumber)
+	err := scanRevision(row, &revision)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoRevisionFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting song revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// RestoreSongRevision возвращает песню к состоянию указанной ревизии. Восстановление записывается в историю как новая ревизия.
+// Если у исполнителя из ревизии уже есть другая живая песня с таким же названием, возвращается *SongExistsError
+func RestoreSongRevision(songId, revisionNumber int, actor string) error {
+	revision, err := GetSongRevision(songId, revisionNumber)
+	if err != nil {
+		return err
+	}
+
+	song := revision.Song
+	song.ReleaseDate, err = ParseReleaseDate(song.ReleaseDate)
+	if err != nil {
+		return err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, songId, AnyVersion, false); err != nil {
+		return err
+	}
+	if err := checkSongKeyFree(tx, songId, song.Title, song.Group); err != nil {
+		return err
+	}
+	if err := updateSong(tx, songId, &song); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, songId, ChangeRestore, actor); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
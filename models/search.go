@@ -0,0 +1,118 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// querier позволяет выполнять одни и те же запросы как через пул соединений, так и внутри транзакции
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// lyricsVector должен совпадать с выражением индекса idx_songs_lyrics, иначе Postgres не сможет его использовать
+const lyricsVector = "to_tsvector('english', lyrics)"
+
+// SearchLibrary выполняет полнотекстовый поиск по текстам песен. Запрос берется из filter.LyricsQuery,
+// остальные фильтры применяются как обычно. Если сортировка не задана, результаты упорядочиваются по релевантности
+func SearchLibrary(filter LibraryFilter, sort []SortField, limit, offset int) ([]entities.LyricsSearchHit, error) {
+	where, args := buildLibraryFilter(filter)
+	args = append(args, filter.LyricsQuery)
+	tsQuery := fmt.Sprintf("websearch_to_tsquery('english', $%d)", len(args))
+
+	query := `SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number, ` +
+		`ts_rank(` + lyricsVector + `, ` + tsQuery + `) AS rank, ` +
+		`ts_headline('english', lyrics, ` + tsQuery + `, 'StartSel=<b>, StopSel=</b>, MaxFragments=2, FragmentDelimiter=" ... "') ` +
+		`FROM songs` + where
+
+	if len(sort) == 0 {
+		query += " ORDER BY rank DESC, id ASC"
+	} else {
+		query += buildOrderBy(sort)
+	}
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	var hits []entities.LyricsSearchHit
+	err := withLibraryFilter(filter, func(q querier) error {
+		var err error
+		hits, err = scanSearchHits(q, query, args)
+		return err
+	})
+	return hits, err
+}
+
+func scanSearchHits(q querier, query string, args []interface{}) ([]entities.LyricsSearchHit, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []entities.LyricsSearchHit{}
+	for rows.Next() {
+		var hit entities.LyricsSearchHit
+		if err := scanSong(rows, &hit.Song, &hit.Rank, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// withLibraryFilter выполняет fn с учетом настроек фильтра. В нечетком режиме запросы выполняются в транзакции,
+// где задан порог pg_trgm.similarity_threshold: оператор % сравнивает именно с ним и при этом использует триграммные индексы
+func withLibraryFilter(filter LibraryFilter, fn func(q querier) error) error {
+	if !filter.Fuzzy {
+		return fn(Db)
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setSimilarityThreshold(tx, filter.Threshold); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setSimilarityThreshold задает порог оператора % до конца транзакции tx
+func setSimilarityThreshold(tx *sql.Tx, threshold float64) error {
+	_, err := tx.Exec("SELECT set_config('pg_trgm.similarity_threshold', $1, true)", fmt.Sprint(threshold))
+	if err != nil {
+		return fmt.Errorf("error while setting similarity threshold: %w", err)
+	}
+	return nil
+}
+
+func isSimilarityOrdered(filter LibraryFilter) bool {
+	return filter.Fuzzy && (filter.Title != "" || filter.Group != "")
+}
+
+// buildSimilarityOrderBy упорядочивает результаты нечеткого поиска по суммарной похожести title и group
+func buildSimilarityOrderBy(filter LibraryFilter, args []interface{}) (string, []interface{}) {
+	similarities := []string{}
+	if filter.Title != "" {
+		args = append(args, filter.Title)
+		similarities = append(similarities, fmt.Sprintf("similarity(title, $%d)", len(args)))
+	}
+	if filter.Group != "" {
+		args = append(args, filter.Group)
+		similarities = append(similarities, fmt.Sprintf("similarity(group_name, $%d)", len(args)))
+	}
+	return " ORDER BY " + strings.Join(similarities, " + ") + " DESC, id ASC", args
+}
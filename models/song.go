@@ -3,45 +3,318 @@ package models
 import (
 	"EffectiveMobileTest/entities"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
 )
 
-var ErrNoSongFound = errors.New("no song found with provided id")
+var (
+	ErrNoSongFound     = errors.New("no song found with provided id")
+	ErrSongInPlaylist  = errors.New("song is in a playlist")
+	ErrVersionMismatch = errors.New("song version doesn't match")
+	ErrSongExists      = errors.New("artist already has a song with such title")
+)
+
+// AnyVersion отключает проверку версии песни при изменении
+const AnyVersion = 0
+
+// AddSong добавляет песню. actor - автор изменения, он сохраняется в истории ревизий.
+// Если у исполнителя уже есть песня с таким же нормализованным названием, возвращается ErrSongExists, а song.Id заполняется id существующей песни
+func AddSong(song *entities.Song, actor string) error {
+	return addSong(song, actor, EnrichmentDone, "")
+}
+
+// AddPendingSong добавляет песню, которую еще нужно обогатить данными стороннего API. Ее возьмет фоновый обработчик.
+// Дубликаты проверяются так же, как в AddSong
+func AddPendingSong(song *entities.Song, actor string) error {
+	return addSong(song, actor, EnrichmentPending, "")
+}
+
+// AddSongWithoutDetails добавляет песню, для которой поставщики метаданных не вернули данные, только с переданными клиентом полями.
+// reason сохраняется как ошибка обогащения. При retry песня остается в очереди pending и ее снова попробуют обогатить фоновые обработчики,
+// иначе она сохраняется со статусом failed, и обогатить ее можно только через RequestEnrichment
+func AddSongWithoutDetails(song *entities.Song, actor, reason string, retry bool) error {
+	if retry {
+		return addSong(song, actor, EnrichmentPending, reason)
+	}
+	return addSong(song, actor, EnrichmentFailed, reason)
+}
+
+func addSong(song *entities.Song, actor, enrichmentStatus, enrichmentError string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongKey(tx, song.Title, song.Group); err != nil {
+		return err
+	}
+	id, err := findSong(tx, song.Title, song.Group, 0)
+	if err == nil {
+		song.Id = id
+		return fmt.Errorf("%w: id %d", ErrSongExists, id)
+	} else if !errors.Is(err, ErrNoSongFound) {
+		return err
+	}
+
+	sources, err := marshalSources(song.Sources)
+	if err != nil {
+		return err
+	}
 
-func AddSong(song *entities.Song) error {
-	_, err := Db.Exec("INSERT INTO songs (title, group_name, release_date, lyrics, link) VALUES ($1, $2, $3, $4, $5)",
-		song.Title, song.Group, song.ReleaseDate, song.Lyrics, song.Link)
+	var releaseDate sql.NullTime
+	row := tx.QueryRow(`WITH `+upsertArtistCTE+` INSERT INTO songs (title, group_name, artist_id, release_date, lyrics, link, metadata_sources,
+			enrichment_status, enrichment_attempts, enrichment_error, enrichment_next_at, enriched_at)
+		SELECT $3::varchar, artist.name, artist.id, NULLIF($4::varchar, '')::date, NULLIF($5::text, ''), NULLIF($6::varchar, ''), $9::jsonb,
+			$7::varchar, CASE WHEN NULLIF($8::text, '') IS NOT NULL THEN 1 ELSE 0 END, NULLIF($8::text, ''),
+			CASE WHEN $7::varchar = 'pending' THEN now() END, CASE WHEN $7::varchar = 'done' THEN now() END
+		FROM artist RETURNING id, group_name, artist_id, release_date, updated_at, version`,
+		entities.CleanArtistName(song.Group), entities.NormalizeArtistName(song.Group), song.Title, song.ReleaseDate, song.Lyrics, song.Link, enrichmentStatus, enrichmentError, sources)
+	err = row.Scan(&song.Id, &song.Group, &song.ArtistId, &releaseDate, &song.UpdatedAt, &song.Version)
 	if err != nil {
 		return fmt.Errorf("error while adding song: %w", err)
 	}
+
+	if err := recordRevision(tx, song.Id, ChangeCreate, actor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+
+	song.ReleaseDate = ""
+	if releaseDate.Valid {
+		song.ReleaseDate = releaseDate.Time.Format("02.01.2006")
+	}
 	return nil
 }
 
-func UpdateSong(id int, song *entities.Song) error {
-	result, err := Db.Exec("UPDATE songs SET title = $1, group_name = $2, release_date = $3, lyrics = $4, link = $5 WHERE id = $6",
-		song.Title, song.Group, song.ReleaseDate, song.Lyrics, song.Link, id)
+func GetSong(id int) (*entities.Song, error) {
+	var song entities.Song
+	var sources []byte
+	row := Db.QueryRow("SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number, updated_at, version, metadata_sources FROM songs WHERE id = $1 AND deleted_at IS NULL", id)
+	err := scanSong(row, &song, &song.UpdatedAt, &song.Version, &sources)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, ErrNoSongFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error while getting song: %w", err)
+	}
+	if err := json.Unmarshal(sources, &song.Sources); err != nil {
+		return nil, fmt.Errorf("error while reading song sources: %w", err)
+	}
+	return &song, nil
+}
+
+func checkSongExists(id int) error {
+	var isExists bool
+	err := Db.QueryRow("SELECT EXISTS(SELECT 1 FROM songs WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&isExists)
 	if err != nil {
-		return fmt.Errorf("error while updating song: %w", err)
+		return fmt.Errorf("error while checking song: %w", err)
 	}
-	ra, err := result.RowsAffected()
+	if !isExists {
+		return ErrNoSongFound
+	}
+	return nil
+}
+
+// lockSongVersion блокирует строку песни до конца транзакции и сверяет ее версию с ожидаемой клиентом.
+// AnyVersion пропускает сверку. withTrashed позволяет найти песню, которая находится в корзине
+func lockSongVersion(tx *sql.Tx, id, version int, withTrashed bool) error {
+	query := "SELECT version FROM songs WHERE id = $1"
+	if !withTrashed {
+		query += " AND deleted_at IS NULL"
+	}
+
+	var current int
+	err := tx.QueryRow(query+" FOR UPDATE", id).Scan(&current)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoSongFound
+	} else if err != nil {
+		return fmt.Errorf("error while checking song version: %w", err)
+	}
+	if version != AnyVersion && version != current {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// SourceClient - происхождение поля песни, которое передал клиент, а не поставщик метаданных
+const SourceClient = "client"
+
+// ClientSources отмечает заполненные поля песни как переданные клиентом
+func ClientSources(song *entities.Song) map[string]string {
+	sources := map[string]string{}
+	if song.ReleaseDate != "" {
+		sources[entities.FieldReleaseDate] = SourceClient
+	}
+	if song.Lyrics != "" {
+		sources[entities.FieldLyrics] = SourceClient
+	}
+	if song.Link != "" {
+		sources[entities.FieldLink] = SourceClient
+	}
+	return sources
+}
+
+// marshalSources готовит происхождение полей песни для столбца metadata_sources
+func marshalSources(sources map[string]string) (string, error) {
+	if sources == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(sources)
 	if err != nil {
-		return fmt.Errorf("error while checking affecting rows: %w", err)
+		return "", fmt.Errorf("error while encoding song sources: %w", err)
 	}
-	if ra == 0 {
+	return string(data), nil
+}
+
+// metadataSourcesSQL возвращает новое значение metadata_sources для UPDATE songs по выражениям новых releaseDate, lyrics и link:
+// измененное поле получает происхождение client, у неизменного поля происхождение сохраняется, у очищенного - удаляется
+func metadataSourcesSQL(releaseDate, lyrics, link string) string {
+	field := func(key, column, value string) string {
+		return fmt.Sprintf("'%s', CASE WHEN songs.%s IS NOT DISTINCT FROM %s THEN songs.metadata_sources->'%s' WHEN %s IS NOT NULL THEN to_jsonb('%s'::text) END",
+			key, column, value, key, value, SourceClient)
+	}
+	return "jsonb_strip_nulls(jsonb_build_object(" + field(entities.FieldReleaseDate, "release_date", releaseDate) + ", " +
+		field(entities.FieldLyrics, "lyrics", lyrics) + ", " + field(entities.FieldLink, "link", link) + "))"
+}
+
+// updateSong перезаписывает все поля песни и заполняет song.Version новой версией. Пустые releaseDate, lyrics и link сохраняются как NULL.
+// Песню нужно заранее заблокировать через lockSongVersion, чтобы исполнитель не создавался для несуществующей песни
+func updateSong(tx *sql.Tx, id int, song *entities.Song) error {
+	row := tx.QueryRow("WITH "+upsertArtistCTE+" UPDATE songs SET title = $3, group_name = artist.name, artist_id = artist.id, release_date = NULLIF($4::varchar, '')::date, lyrics = NULLIF($5::text, ''), link = NULLIF($6::varchar, ''), metadata_sources = "+metadataSourcesSQL("NULLIF($4::varchar, '')::date", "NULLIF($5::text, '')", "NULLIF($6::varchar, '')")+", version = songs.version + 1, updated_at = now() FROM artist WHERE songs.id = $7 AND songs.deleted_at IS NULL RETURNING songs.version",
+		entities.CleanArtistName(song.Group), entities.NormalizeArtistName(song.Group), song.Title, song.ReleaseDate, song.Lyrics, song.Link, id)
+	err := row.Scan(&song.Version)
+	if err != nil && err == sql.ErrNoRows {
 		return ErrNoSongFound
+	} else if err != nil {
+		return fmt.Errorf("error while updating song: %w", err)
+	}
+	return nil
+}
+
+// UpdateSong перезаписывает песню. Если version не AnyVersion, изменение выполняется только для этой версии песни,
+// иначе возвращается ErrVersionMismatch
+func UpdateSong(id int, song *entities.Song, actor string, version int) error {
+	return replaceSong(id, song, actor, version, ChangeUpdate)
+}
+
+// MergeSongDetails дополняет существующую песню непустыми releaseDate (YYYY-MM-DD), lyrics и link из song,
+// например при повторном добавлении песни. Уже заполненные поля, для которых новых данных нет, сохраняются,
+// а происхождение из song.Sources записывается только для полученных полей. Версия не проверяется
+func MergeSongDetails(id int, song *entities.Song, actor string) error {
+	sources, err := marshalSources(song.Sources)
+	if err != nil {
+		return err
+	}
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, id, AnyVersion, false); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE songs SET release_date = COALESCE(NULLIF($2::varchar, '')::date, release_date),
+		lyrics = COALESCE(NULLIF($3::text, ''), lyrics), link = COALESCE(NULLIF($4::varchar, ''), link), metadata_sources = metadata_sources || $5::jsonb,
+		version = version + 1, updated_at = now()
+		WHERE id = $1`, id, song.ReleaseDate, song.Lyrics, song.Link, sources)
+	if err != nil {
+		return fmt.Errorf("error while merging song details: %w", err)
+	}
+	if err := recordRevision(tx, id, ChangeUpdate, actor); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// SavePatchedSong сохраняет песню, полученную применением merge patch или JSON Patch к документу песни.
+// В отличие от PatchSong, пустые поля очищаются. Версия проверяется так же, как в UpdateSong
+func SavePatchedSong(id int, song *entities.Song, actor string, version int) error {
+	return replaceSong(id, song, actor, version, ChangePatch)
+}
+
+// replaceSong перезаписывает песню. Измененные поля считаются переданными клиентом
+func replaceSong(id int, song *entities.Song, actor string, version int, changeType string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, id, version, false); err != nil {
+		return err
+	}
+	if err := updateSong(tx, id, song); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, id, changeType, actor); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
 	}
 	return nil
 }
 
-func PatchSong(id int, song *entities.Song) error {
-	result, err := Db.Exec("UPDATE songs SET title = COALESCE(NULLIF($1, ''), title), group_name = COALESCE(NULLIF($2, ''), group_name), release_date = COALESCE(NULLIF($3, '')::date, release_date), lyrics = COALESCE(NULLIF($4, ''), lyrics), link = COALESCE(NULLIF($5, ''), link) WHERE id = $6",
-		song.Title, song.Group, song.ReleaseDate, song.Lyrics, song.Link, id)
+// PatchSong изменяет непустые поля песни. Версия проверяется так же, как в UpdateSong
+func PatchSong(id int, song *entities.Song, actor string, version int) error {
+	tx, err := Db.Begin()
 	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, id, version, false); err != nil {
+		return err
+	}
+
+	row := tx.QueryRow("WITH "+upsertArtistCTE+" UPDATE songs SET title = COALESCE(NULLIF($3, ''), title), group_name = COALESCE((SELECT name FROM artist), group_name), artist_id = COALESCE((SELECT id FROM artist), artist_id), release_date = COALESCE(NULLIF($4, '')::date, release_date), lyrics = COALESCE(NULLIF($5, ''), lyrics), link = COALESCE(NULLIF($6, ''), link), metadata_sources = "+metadataSourcesSQL("COALESCE(NULLIF($4, '')::date, release_date)", "COALESCE(NULLIF($5, ''), lyrics)", "COALESCE(NULLIF($6, ''), link)")+", version = version + 1, updated_at = now() WHERE id = $7 AND deleted_at IS NULL RETURNING version",
+		entities.CleanArtistName(song.Group), entities.NormalizeArtistName(song.Group), song.Title, song.ReleaseDate, song.Lyrics, song.Link, id)
+	err = row.Scan(&song.Version)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoSongFound
+	} else if err != nil {
 		return fmt.Errorf("error while patching song: %w", err)
 	}
+
+	if err := recordRevision(tx, id, ChangePatch, actor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteSong переносит песню в корзину. Окончательно она удаляется фоновой очисткой корзины или через PurgeSong.
+// Если песня входит в плейлисты, поведение задается переменной PLAYLIST_SONG_DELETE:
+// cascade удаляет песню из плейлистов, restrict (по умолчанию) отказывает с ошибкой ErrSongInPlaylist
+func DeleteSong(id int, actor string, version int) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, id, version, false); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE songs SET deleted_at = now(), version = version + 1 WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("error while deleting song: %w", err)
+	}
 	ra, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("error while checking affecting rows: %w", err)
@@ -49,12 +322,59 @@ func PatchSong(id int, song *entities.Song) error {
 	if ra == 0 {
 		return ErrNoSongFound
 	}
+
+	// песня в корзине не должна оставаться в плейлистах, иначе ее нельзя будет удалить окончательно
+	if os.Getenv("PLAYLIST_SONG_DELETE") == "cascade" {
+		if err := removeSongFromPlaylists(tx, id); err != nil {
+			return err
+		}
+	} else {
+		var inPlaylist bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM playlist_entries WHERE song_id = $1)", id).Scan(&inPlaylist)
+		if err != nil {
+			return fmt.Errorf("error while checking playlist entries: %w", err)
+		}
+		if inPlaylist {
+			return ErrSongInPlaylist
+		}
+	}
+
+	if err := recordRevision(tx, id, ChangeDelete, actor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
 	return nil
 }
 
-func DeleteSong(id int) error {
-	result, err := Db.Exec("DELETE FROM songs WHERE id = $1", id)
+// PurgeSong удаляет песню окончательно, в том числе из корзины. Песни в плейлистах обрабатываются так же, как в DeleteSong
+func PurgeSong(id int, actor string, version int) error {
+	tx, err := Db.Begin()
 	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockSongVersion(tx, id, version, true); err != nil {
+		return err
+	}
+
+	// снимок сохраняется до удаления, пока строка еще существует
+	if err := recordRevision(tx, id, ChangePurge, actor); err != nil {
+		return err
+	}
+
+	if os.Getenv("PLAYLIST_SONG_DELETE") == "cascade" {
+		if err := removeSongFromPlaylists(tx, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM songs WHERE id = $1", id)
+	if err != nil && isPqError(err, pqForeignKeyViolation) {
+		return ErrSongInPlaylist
+	} else if err != nil {
 		return fmt.Errorf("error while deleting song: %w", err)
 	}
 	ra, err := result.RowsAffected()
@@ -64,28 +384,24 @@ func DeleteSong(id int) error {
 	if ra == 0 {
 		return ErrNoSongFound
 	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
 	return nil
 }
 
 func GetSongLyrics(id int, page int, versesPerPage int) ([]string, error) {
-	var song entities.Song
-	row := Db.QueryRow("SELECT lyrics FROM songs WHERE id = $1", id)
-	err := row.Scan(&song.Lyrics)
+	var lyrics sql.NullString
+	row := Db.QueryRow("SELECT lyrics FROM songs WHERE id = $1 AND deleted_at IS NULL", id)
+	err := row.Scan(&lyrics)
 	if err != nil && err == sql.ErrNoRows {
 		return nil, ErrNoSongFound
 	} else if err != nil {
 		return nil, err
 	}
 
-	serverOs := os.Getenv("SERVER_OS")
-	var splitter string
-	if serverOs == "windows" { // В windows и linux конец строки задается разной последовательностью управляющих символов
-		splitter = "\r\n\r\n"
-	} else {
-		splitter = "\n\n"
-	}
-
-	verses := strings.Split(song.Lyrics, splitter)
+	verses := strings.Split(lyrics.String, lyricsVerseSplitter())
 
 	start := (page - 1) * versesPerPage
 	end := start + versesPerPage
@@ -98,3 +414,16 @@ func GetSongLyrics(id int, page int, versesPerPage int) ([]string, error) {
 
 	return verses[start:end], nil
 }
+
+// lyricsNewline возвращает последовательность конца строки в текстах песен
+func lyricsNewline() string {
+	if os.Getenv("SERVER_OS") == "windows" { // В windows и linux конец строки задается разной последовательностью управляющих символов
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// lyricsVerseSplitter возвращает разделитель куплетов - пустую строку между ними
+func lyricsVerseSplitter() string {
+	return lyricsNewline() + lyricsNewline()
+}
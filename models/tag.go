@@ -0,0 +1,165 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNoTagFound   = errors.New("song has no such tag")
+	ErrTagKindClash = errors.New("tag with such name already exists with another kind")
+)
+
+const (
+	TagKindGenre = "genre"
+	TagKindTag   = "tag"
+)
+
+// MaxTagNameLength - размер столбца tags.name
+const MaxTagNameLength = 64
+
+// NormalizeTagName приводит тег к виду, в котором он хранится: нижний регистр и одиночные пробелы
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func GetSongTags(songId int) ([]entities.Tag, error) {
+	if err := checkSongExists(songId); err != nil {
+		return nil, err
+	}
+
+	rows, err := Db.Query("SELECT tags.name, tags.kind FROM song_tags JOIN tags ON tags.id = song_tags.tag_id WHERE song_tags.song_id = $1 ORDER BY tags.kind, tags.name", songId)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting song tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []entities.Tag{}
+	for rows.Next() {
+		var tag entities.Tag
+		if err := rows.Scan(&tag.Name, &tag.Kind); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AttachSongTag привязывает тег к песне, создавая тег при необходимости. Повторная привязка не считается ошибкой
+func AttachSongTag(songId int, tag *entities.Tag) error {
+	tag.Name = NormalizeTagName(tag.Name)
+
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tagId int
+	var kind string
+	err = tx.QueryRow("INSERT INTO tags (name, kind) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id, kind",
+		tag.Name, tag.Kind).Scan(&tagId, &kind)
+	if err != nil {
+		return fmt.Errorf("error while adding tag: %w", err)
+	}
+	if kind != tag.Kind {
+		return ErrTagKindClash
+	}
+
+	result, err := tx.Exec("INSERT INTO song_tags (song_id, tag_id) SELECT id, $2 FROM songs WHERE id = $1 AND deleted_at IS NULL ON CONFLICT DO NOTHING", songId, tagId)
+	if err != nil {
+		return fmt.Errorf("error while attaching tag: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		// ничего не вставлено: либо тег уже есть у песни, либо песни нет или она в корзине
+		if err := checkSongExists(songId); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+func DetachSongTag(songId int, name string) error {
+	result, err := Db.Exec("DELETE FROM song_tags USING tags WHERE song_tags.tag_id = tags.id AND song_tags.song_id = $1 AND tags.name = $2",
+		songId, NormalizeTagName(name))
+	if err != nil {
+		return fmt.Errorf("error while detaching tag: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoTagFound
+	}
+	return nil
+}
+
+// GetTagCounts возвращает теги с количеством песен для облака тегов. Пустой kind означает все виды тегов
+func GetTagCounts(kind string) ([]entities.TagCount, error) {
+	query := "SELECT tags.name, tags.kind, COUNT(song_tags.song_id) FROM tags JOIN song_tags ON song_tags.tag_id = tags.id" +
+		" JOIN songs ON songs.id = song_tags.song_id WHERE songs.deleted_at IS NULL"
+	args := []interface{}{}
+	if kind != "" {
+		query += " AND tags.kind = $1"
+		args = append(args, kind)
+	}
+	query += " GROUP BY tags.id ORDER BY COUNT(song_tags.song_id) DESC, tags.name"
+
+	rows, err := Db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting tag counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []entities.TagCount{}
+	for rows.Next() {
+		var count entities.TagCount
+		if err := rows.Scan(&count.Name, &count.Kind, &count.Songs); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// buildTagsFilter формирует условие отбора песен по тегам: хотя бы один из тегов (all = false) или все теги сразу (all = true)
+func buildTagsFilter(tags []string, all bool, args []interface{}) (string, []interface{}) {
+	names := []string{}
+	used := map[string]bool{}
+	for _, tag := range tags {
+		name := NormalizeTagName(tag)
+		if name != "" && !used[name] {
+			used[name] = true
+			names = append(names, name)
+		}
+	}
+
+	args = append(args, pq.Array(names))
+	condition := fmt.Sprintf(" AND id IN (SELECT song_tags.song_id FROM song_tags JOIN tags ON tags.id = song_tags.tag_id WHERE tags.name = ANY($%d)", len(args))
+	if all {
+		args = append(args, len(names))
+		condition += fmt.Sprintf(" GROUP BY song_tags.song_id HAVING COUNT(DISTINCT tags.id) = $%d", len(args))
+	}
+	return condition + ")", args
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"EffectiveMobileTest/entities"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrNoTrashedSongFound = errors.New("no song with provided id in trash")
+
+const (
+	defaultTrashRetentionDays = 30
+	defaultTrashPurgeInterval = time.Hour
+	trashPurgerActor          = "trash purger"
+)
+
+// TrashRetention возвращает срок хранения песен в корзине. Задается в днях переменной TRASH_RETENTION_DAYS
+func TrashRetention() time.Duration {
+	days := defaultTrashRetentionDays
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			logrus.WithField("TRASH_RETENTION_DAYS", value).Warn("Invalid trash retention, default is used")
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetTrash возвращает песни из корзины, начиная с удаленных последними
+func GetTrash(limit, offset int) ([]entities.TrashedSong, error) {
+	rows, err := Db.Query(`SELECT id, title, group_name, artist_id, release_date, lyrics, link, album_id, track_number, deleted_at
+		FROM songs WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting trash: %w", err)
+	}
+	defer rows.Close()
+
+	retention := TrashRetention()
+	trash := []entities.TrashedSong{}
+	for rows.Next() {
+		var song entities.TrashedSong
+		if err := scanSong(rows, &song.Song, &song.DeletedAt); err != nil {
+			return nil, err
+		}
+		song.PurgeAt = song.DeletedAt.Add(retention)
+		trash = append(trash, song)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return trash, nil
+}
+
+// RestoreSong возвращает песню из корзины. Восстановление записывается в историю ревизий.
+// Если у исполнителя уже есть живая песня с таким же названием, возвращается *SongExistsError
+func RestoreSong(id int, actor string) error {
+	tx, err := Db.Begin()
+	if err != nil {
+		return fmt.Errorf("error while starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var title, group string
+	err = tx.QueryRow("SELECT title, group_name FROM songs WHERE id = $1 AND deleted_at IS NOT NULL", id).Scan(&title, &group)
+	if err != nil && err == sql.ErrNoRows {
+		return ErrNoTrashedSongFound
+	} else if err != nil {
+		return fmt.Errorf("error while getting trashed song: %w", err)
+	}
+	if err := checkSongKeyFree(tx, id, title, group); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE songs SET deleted_at = NULL, version = version + 1, updated_at = now() WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("error while restoring song: %w", err)
+	}
+	ra, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	if ra == 0 {
+		return ErrNoTrashedSongFound
+	}
+
+	if err := recordRevision(tx, id, ChangeUndelete, actor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error while committing transaction: %w", err)
+	}
+	return nil
+}
+
+// PurgeTrash окончательно удаляет песни, пролежавшие в корзине дольше retention, и возвращает их количество.
+// Последний снимок каждой песни сохраняется в истории ревизий тем же запросом
+func PurgeTrash(retention time.Duration) (int64, error) {
+	result, err := Db.Exec(`WITH purged AS (
+			DELETE FROM songs WHERE deleted_at < now() - make_interval(secs => $1)
+			AND NOT EXISTS (SELECT 1 FROM playlist_entries WHERE playlist_entries.song_id = songs.id)
+			RETURNING id, title, group_name, artist_id, release_date, lyrics, link
+		)
+		INSERT INTO song_revisions (song_id, revision, title, group_name, artist_id, release_date, lyrics, link, change_type, actor)
+		SELECT id, COALESCE((SELECT MAX(revision) FROM song_revisions WHERE song_id = purged.id), 0) + 1,
+			title, group_name, artist_id, release_date, lyrics, link, $2, $3
+		FROM purged`, retention.Seconds(), ChangePurge, trashPurgerActor)
+	if err != nil {
+		return 0, fmt.Errorf("error while purging trash: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error while checking affecting rows: %w", err)
+	}
+	return purged, nil
+}
+
+// StartTrashPurger запускает фоновую очистку корзины. Период очистки задается переменной TRASH_PURGE_INTERVAL
+// в формате time.ParseDuration (например 30m), срок хранения - переменной TRASH_RETENTION_DAYS
+func StartTrashPurger() {
+	interval := defaultTrashPurgeInterval
+	if value := os.Getenv("TRASH_PURGE_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			logrus.WithField("TRASH_PURGE_INTERVAL", value).Warn("Invalid trash purge interval, default is used")
+		} else {
+			interval = parsed
+		}
+	}
+	retention := TrashRetention()
+
+	logrus.WithFields(logrus.Fields{
+		"interval":  interval,
+		"retention": retention,
+	}).Info("Trash purger started")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := PurgeTrash(retention)
+			if err != nil {
+				logrus.WithField("error", err).Error("Error purging trash")
+				continue
+			}
+			if purged > 0 {
+				logrus.WithField("purged", purged).Info("Trash purged")
+			}
+		}
+	}()
+}
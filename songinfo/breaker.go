@@ -0,0 +1,186 @@
+package songinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"EffectiveMobileTest/entities"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBreakerFailures         = 5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// Состояния автомата. В closed запросы идут в API, в open сразу отклоняются,
+// в half-open пропускается HalfOpenRequests пробных запросов, по результатам которых автомат закрывается или снова размыкается
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("song info API circuit is open")
+
+// CircuitOpenError возвращается без обращения к API, пока автомат разомкнут
+type CircuitOpenError struct {
+	RetryAfter time.Duration // через сколько автомат пропустит пробный запрос
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type BreakerConfig struct {
+	Failures         int           // подряд неудачных запросов, после которых автомат размыкается
+	OpenTimeout      time.Duration // время в состоянии open до пробных запросов
+	HalfOpenRequests int           // пробных запросов в half-open; все они должны быть успешными, чтобы автомат замкнулся
+}
+
+// breakerOutcome - результат запроса с точки зрения автомата
+type breakerOutcome int
+
+const (
+	outcomeSuccess breakerOutcome = iota
+	outcomeFailure
+	outcomeIgnored // отмена запроса клиентом или ошибка, которая не говорит о недоступности API, например 404 или неразборчивый ответ
+)
+
+// Breaker - автоматический выключатель перед API: после серии сбоев перестает обращаться к нему на OpenTimeout,
+// чтобы не ждать таймаутов на каждом запросе и не нагружать API, пока он не восстановится
+type Breaker struct {
+	config BreakerConfig
+
+	mu        sync.Mutex
+	state     string
+	failures  int // подряд в closed
+	successes int // в half-open
+	inFlight  int // пробных запросов в half-open
+	openedAt  time.Time
+}
+
+func NewBreaker(config BreakerConfig) *Breaker {
+	return &Breaker{config: config, state: StateClosed}
+}
+
+// allow решает, можно ли обратиться к API. Если можно, возвращает функцию, которой нужно сообщить результат обращения
+func (b *Breaker) allow() (func(breakerOutcome), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		wait := b.config.OpenTimeout - time.Since(b.openedAt)
+		if wait > 0 {
+			return nil, &CircuitOpenError{RetryAfter: wait}
+		}
+		b.setState(StateHalfOpen)
+	}
+
+	state := b.state
+	if state == StateHalfOpen {
+		if b.inFlight >= b.config.HalfOpenRequests {
+			return nil, &CircuitOpenError{RetryAfter: time.Second}
+		}
+		b.inFlight++
+	}
+	return func(outcome breakerOutcome) { b.done(state, outcome) }, nil
+}
+
+// done учитывает результат обращения к API, начатого в состоянии state. Результаты запросов,
+// начатых до смены состояния, не учитываются, чтобы старые сбои не размыкали восстановившийся автомат
+func (b *Breaker) done(state string, outcome breakerOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if state == StateHalfOpen {
+		b.inFlight--
+	}
+	if state != b.state {
+		return
+	}
+
+	switch {
+	case outcome == outcomeFailure && state == StateHalfOpen:
+		b.setState(StateOpen)
+	case outcome == outcomeFailure:
+		b.failures++
+		if b.failures >= b.config.Failures {
+			b.setState(StateOpen)
+		}
+	case outcome == outcomeSuccess && state == StateHalfOpen:
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.setState(StateClosed)
+		}
+	case outcome == outcomeSuccess:
+		b.failures = 0
+	}
+}
+
+// setState вызывается под b.mu
+func (b *Breaker) setState(state string) {
+	fields := logrus.Fields{
+		"from":     b.state,
+		"state":    state,
+		"failures": b.failures,
+	}
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = time.Now()
+		fields["open_timeout"] = b.config.OpenTimeout
+		logrus.WithFields(fields).Error("Song info API circuit opened")
+	case StateHalfOpen:
+		logrus.WithFields(fields).Warn("Song info API circuit half-opened, probing")
+	default:
+		logrus.WithFields(fields).Info("Song info API circuit closed")
+	}
+}
+
+// Status возвращает текущее состояние автомата
+func (b *Breaker) Status() entities.CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := entities.CircuitStatus{
+		State:            b.state,
+		Failures:         b.failures,
+		FailureThreshold: b.config.Failures,
+		OpenTimeout:      b.config.OpenTimeout.Seconds(),
+	}
+	if b.state == StateOpen {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+		if wait := b.config.OpenTimeout - time.Since(b.openedAt); wait > 0 {
+			status.RetryAfter = wait.Seconds()
+		}
+	}
+	return status
+}
+
+// outcomeOf относит к сбоям только ошибки, говорящие о недоступности API: таймауты, сетевые ошибки и ответы 5xx.
+// Ответ, который не удалось разобрать, означает, что API доступен, поэтому автомат его не учитывает
+func outcomeOf(err error) breakerOutcome {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case errors.Is(err, context.Canceled):
+		return outcomeIgnored
+	case isRetryable(err):
+		return outcomeFailure
+	default:
+		return outcomeIgnored
+	}
+}
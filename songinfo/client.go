@@ -0,0 +1,269 @@
+package songinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"EffectiveMobileTest/entities"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultRetries    = 2
+	defaultBackoff    = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+var (
+	ErrTimeout         = errors.New("song info API timed out")
+	ErrUnavailable     = errors.New("song info API is unavailable")
+	ErrInvalidResponse = errors.New("song info API returned invalid response")
+)
+
+// StatusError - ответ API с кодом, отличным от 200
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("song info API responded with status %d", e.StatusCode)
+}
+
+// Is позволяет проверять ответ 404 через errors.Is(err, ErrNotFound), как и у остальных поставщиков
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// SongDetail - метаданные песни. Нулевое поле означает, что поставщик его не знает
+type SongDetail struct {
+	ReleaseDate time.Time
+	Lyrics      string
+	Link        string
+	Sources     map[string]string // поле -> имя поставщика, заполняется Registry
+}
+
+// songDetailResponse - тело ответа API, дата приходит в формате DD.MM.YYYY
+type songDetailResponse struct {
+	ReleaseDate string `json:"releaseDate"`
+	Lyrics      string `json:"lyrics"`
+	Link        string `json:"link"`
+}
+
+type Config struct {
+	BaseURL    string        // адрес API вида host:port или http://host:port
+	Timeout    time.Duration // ограничение на одну попытку
+	Retries    int           // количество повторов после первой попытки
+	Backoff    time.Duration // задержка перед первым повтором, дальше она удваивается
+	MaxBackoff time.Duration
+	Breaker    BreakerConfig
+}
+
+// ConfigFromEnv читает настройки клиента из переменных API_URL, API_TIMEOUT, API_RETRIES, API_RETRY_BACKOFF
+// и настройки автомата из BREAKER_FAILURES, BREAKER_OPEN_TIMEOUT и BREAKER_HALF_OPEN_REQUESTS.
+// Некорректные значения заменяются значениями по умолчанию
+func ConfigFromEnv() Config {
+	return Config{
+		BaseURL:    os.Getenv("API_URL"),
+		Timeout:    envDuration("API_TIMEOUT", defaultTimeout),
+		Retries:    envInt("API_RETRIES", defaultRetries, 0),
+		Backoff:    envDuration("API_RETRY_BACKOFF", defaultBackoff),
+		MaxBackoff: defaultMaxBackoff,
+		Breaker: BreakerConfig{
+			Failures:         envInt("BREAKER_FAILURES", defaultBreakerFailures, 1),
+			OpenTimeout:      envDuration("BREAKER_OPEN_TIMEOUT", defaultBreakerOpenTimeout),
+			HalfOpenRequests: envInt("BREAKER_HALF_OPEN_REQUESTS", defaultBreakerHalfOpenRequests, 1),
+		},
+	}
+}
+
+func envInt(name string, defaultValue, min int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min {
+		logrus.WithField(name, value).Warn("Invalid config value, default is used")
+		return defaultValue
+	}
+	return parsed
+}
+
+func envDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		logrus.WithField(name, value).Warn("Invalid config value, default is used")
+		return defaultValue
+	}
+	return parsed
+}
+
+// ClientProviderName - имя стороннего API среди поставщиков метаданных
+const ClientProviderName = "api"
+
+// Client запрашивает дату выхода, текст и ссылку песни у стороннего API (GET /info?group=&song=). Является поставщиком метаданных
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	breaker    *Breaker
+}
+
+func NewClient(config Config) *Client {
+	if !strings.Contains(config.BaseURL, "://") {
+		config.BaseURL = "http://" + config.BaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	return &Client{config: config, httpClient: &http.Client{}, breaker: NewBreaker(config.Breaker)}
+}
+
+// GetSongDetail запрашивает данные песни. Сетевые ошибки, таймауты попыток и ответы 5xx повторяются с экспоненциальной задержкой и случайным разбросом.
+// Отмена ctx прерывает запрос и ожидание повтора. Ошибки: ErrTimeout, ErrUnavailable, ErrInvalidResponse, *StatusError,
+// *CircuitOpenError, пока API считается недоступным, или ошибка ctx, если запрос отменил сам клиент
+func (c *Client) GetSongDetail(ctx context.Context, group, song string) (*SongDetail, error) {
+	done, err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	detail, err := c.getSongDetail(ctx, group, song)
+	done(outcomeOf(err))
+	return detail, err
+}
+
+func (c *Client) Name() string {
+	return ClientProviderName
+}
+
+func (c *Client) Lookup(ctx context.Context, group, title string) (*SongDetail, error) {
+	return c.GetSongDetail(ctx, group, title)
+}
+
+// BreakerStatus возвращает состояние автомата перед API
+func (c *Client) BreakerStatus() entities.CircuitStatus {
+	return c.breaker.Status()
+}
+
+func (c *Client) getSongDetail(ctx context.Context, group, song string) (*SongDetail, error) {
+	for attempt := 0; ; attempt++ {
+		detail, err := c.fetch(ctx, group, song)
+		if err == nil {
+			return detail, nil
+		}
+		if !isRetryable(err) || attempt >= c.config.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := c.backoff(attempt)
+		logrus.WithFields(logrus.Fields{
+			"group":   group,
+			"title":   song,
+			"attempt": attempt + 1,
+			"delay":   delay,
+			"error":   err,
+		}).Warn("Song info API request failed, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, contextError(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) fetch(ctx context.Context, group, song string) (*SongDetail, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	infoURL := fmt.Sprintf("%s/info?group=%s&song=%s", c.config.BaseURL, url.QueryEscape(group), url.QueryEscape(song))
+	logrus.WithFields(logrus.Fields{
+		"title": song,
+		"group": group,
+		"url":   infoURL,
+	}).Debug("Fetching song data from side API")
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, infoURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, contextError(ctx.Err())
+		}
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return nil, fmt.Errorf("%w: %v", ErrTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var body songDetailResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		if attemptCtx.Err() != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	// API может не знать дату выхода песни: тогда дата остается нулевой, а текст и ссылка все равно используются
+	detail := &SongDetail{Lyrics: body.Lyrics, Link: body.Link}
+	if body.ReleaseDate != "" {
+		detail.ReleaseDate, err = time.Parse("02.01.2006", body.ReleaseDate)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"group":       group,
+				"title":       song,
+				"releaseDate": body.ReleaseDate,
+			}).Warn("Side API returned release date in unexpected format, it is ignored")
+		}
+	}
+	return detail, nil
+}
+
+// backoff возвращает задержку перед повтором: удвоенную для каждой следующей попытки, но не больше MaxBackoff,
+// со случайным разбросом в ее вторую половину, чтобы параллельные запросы не повторялись одновременно
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.config.Backoff << attempt
+	if delay <= 0 || delay > c.config.MaxBackoff {
+		delay = c.config.MaxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable)
+}
+
+// contextError переводит истечение срока запроса клиента в ErrTimeout. Отмена запроса клиентом возвращается как есть
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package songinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// FileProviderName - имя локального каталога среди поставщиков метаданных
+const FileProviderName = "file"
+
+// catalogueEntry - запись локального каталога. Дата в формате DD.MM.YYYY, как в ответе стороннего API; любое из полей releaseDate, lyrics и link можно не заполнять
+type catalogueEntry struct {
+	Group       string `json:"group" yaml:"group"`
+	Title       string `json:"title" yaml:"title"`
+	ReleaseDate string `json:"releaseDate" yaml:"releaseDate"`
+	Lyrics      string `json:"lyrics" yaml:"lyrics"`
+	Link        string `json:"link" yaml:"link"`
+}
+
+// FileProvider берет метаданные из локального каталога, чтобы сервис мог работать без стороннего API.
+// Каталог читается один раз при создании; исполнитель и название сравниваются так же, как при поиске дубликатов: без учета регистра, знаков препинания и лишних пробелов
+type FileProvider struct {
+	songs map[string]SongDetail
+}
+
+// NewFileProvider читает каталог из файла path - массив записей с полями group, title, releaseDate, lyrics и link.
+// Файл с расширением .yaml или .yml читается как YAML, остальные - как JSON
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading metadata catalogue: %w", err)
+	}
+
+	var entries []catalogueEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &entries)
+	default:
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing metadata catalogue: %w", err)
+	}
+
+	provider := &FileProvider{songs: map[string]SongDetail{}}
+	for i, entry := range entries {
+		if strings.TrimSpace(entry.Group) == "" || strings.TrimSpace(entry.Title) == "" {
+			return nil, fmt.Errorf("metadata catalogue entry %d: group and title are required", i)
+		}
+		detail := SongDetail{Lyrics: entry.Lyrics, Link: entry.Link}
+		if entry.ReleaseDate != "" {
+			detail.ReleaseDate, err = time.Parse("02.01.2006", entry.ReleaseDate)
+			if err != nil {
+				return nil, fmt.Errorf("metadata catalogue entry %d: release date should be in format DD.MM.YYYY: %w", i, err)
+			}
+		}
+
+		key := normalizeKey(entry.Group, entry.Title)
+		if _, ok := provider.songs[key]; ok {
+			logrus.WithFields(logrus.Fields{
+				"group": entry.Group,
+				"title": entry.Title,
+				"entry": i,
+			}).Warn("Duplicate song in metadata catalogue, the first entry is used")
+			continue
+		}
+		provider.songs[key] = detail
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":  path,
+		"songs": len(provider.songs),
+	}).Info("Metadata catalogue loaded")
+	return provider, nil
+}
+
+func (p *FileProvider) Name() string {
+	return FileProviderName
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, group, title string) (*SongDetail, error) {
+	detail, ok := p.songs[normalizeKey(group, title)]
+	if !ok {
+		return nil, fmt.Errorf("%w in catalogue: %s - %s", ErrNotFound, group, title)
+	}
+	return &detail, nil
+}
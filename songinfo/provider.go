@@ -0,0 +1,150 @@
+package songinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"EffectiveMobileTest/entities"
+)
+
+// Режимы Registry
+const (
+	ModeFirst = "first" // все поля берутся у первого по приоритету поставщика, который знает песню
+	ModeMerge = "merge" // каждое поле берется у первого по приоритету поставщика, у которого оно заполнено
+)
+
+var (
+	ErrNotFound    = errors.New("song is not found by metadata providers")
+	ErrUnknownMode = errors.New("unknown metadata providers mode")
+	ErrNoProviders = errors.New("no metadata providers configured")
+)
+
+// Provider - источник метаданных песни. Если песня поставщику неизвестна, Lookup возвращает ошибку, совместимую с ErrNotFound.
+// Незаполненные поля SongDetail остаются нулевыми
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, group, title string) (*SongDetail, error)
+}
+
+// Registry опрашивает поставщиков в порядке приоритета и заполняет SongDetail.Sources: у какого поставщика взято каждое поле
+type Registry struct {
+	mode      string
+	providers []Provider
+}
+
+func NewRegistry(mode string, providers ...Provider) (*Registry, error) {
+	if mode != ModeFirst && mode != ModeMerge {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMode, mode)
+	}
+	if len(providers) == 0 {
+		return nil, ErrNoProviders
+	}
+	return &Registry{mode: mode, providers: providers}, nil
+}
+
+// Providers возвращает имена поставщиков в порядке приоритета
+func (r *Registry) Providers() []string {
+	names := []string{}
+	for _, provider := range r.providers {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// Lookup ищет метаданные песни. Если ни один поставщик не вернул данные, возвращается ошибка самого приоритетного поставщика,
+// который не смог ответить, или ErrNotFound, если песню просто никто не знает
+func (r *Registry) Lookup(ctx context.Context, group, title string) (*SongDetail, error) {
+	if r.mode == ModeMerge {
+		return r.merge(ctx, group, title)
+	}
+
+	errs := make([]error, len(r.providers))
+	for i, provider := range r.providers {
+		detail, err := provider.Lookup(ctx, group, title)
+		if err == nil {
+			detail.Sources = map[string]string{}
+			detail.setSources(provider.Name())
+			return detail, nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+	return nil, lookupError(errs)
+}
+
+// merge опрашивает всех поставщиков параллельно и собирает поля в порядке приоритета
+func (r *Registry) merge(ctx context.Context, group, title string) (*SongDetail, error) {
+	details := make([]*SongDetail, len(r.providers))
+	errs := make([]error, len(r.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range r.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details[i], errs[i] = provider.Lookup(ctx, group, title)
+		}()
+	}
+	wg.Wait()
+
+	var merged *SongDetail
+	for i, detail := range details {
+		if errs[i] != nil {
+			continue
+		}
+		if merged == nil {
+			merged = &SongDetail{Sources: map[string]string{}}
+		}
+		name := r.providers[i].Name()
+		if merged.ReleaseDate.IsZero() && !detail.ReleaseDate.IsZero() {
+			merged.ReleaseDate = detail.ReleaseDate
+			merged.Sources[entities.FieldReleaseDate] = name
+		}
+		if merged.Lyrics == "" && detail.Lyrics != "" {
+			merged.Lyrics = detail.Lyrics
+			merged.Sources[entities.FieldLyrics] = name
+		}
+		if merged.Link == "" && detail.Link != "" {
+			merged.Link = detail.Link
+			merged.Sources[entities.FieldLink] = name
+		}
+	}
+	if merged != nil {
+		return merged, nil
+	}
+	if err := ctx.Err(); err != nil && errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	return nil, lookupError(errs)
+}
+
+func lookupError(errs []error) error {
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return ErrNotFound
+}
+
+// setSources отмечает заполненные поля как полученные от поставщика name
+func (d *SongDetail) setSources(name string) {
+	if !d.ReleaseDate.IsZero() {
+		d.Sources[entities.FieldReleaseDate] = name
+	}
+	if d.Lyrics != "" {
+		d.Sources[entities.FieldLyrics] = name
+	}
+	if d.Link != "" {
+		d.Sources[entities.FieldLink] = name
+	}
+}
+
+// normalizeKey приводит исполнителя и название к виду, по которому песни ищутся в каталоге. Правила те же, что при поиске дубликатов
+func normalizeKey(group, title string) string {
+	return entities.NormalizeArtistName(group) + "\x00" + entities.NormalizeSongTitle(title)
+}